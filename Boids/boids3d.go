@@ -0,0 +1,371 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const (
+	boxSize     = 800.0 // The bounding box spans [-boxSize/2, boxSize/2] on every axis
+	boidSpeed3D = 2.0
+	boidSize3D  = 12.0
+
+	fieldOfView = math.Pi / 3
+	nearPlane   = 1.0
+)
+
+type BoundaryMode uint8
+
+const (
+	BoundaryReset  BoundaryMode = iota // Teleport to the center and flip direction, like the 2D flock
+	BoundaryWrap                       // Leave one face, enter through the opposite one
+	BoundaryBounce                     // Reflect off the faces
+)
+
+var boundaryModes = map[string]BoundaryMode{
+	"reset":  BoundaryReset,
+	"wrap":   BoundaryWrap,
+	"bounce": BoundaryBounce,
+}
+
+type Vector3 struct {
+	x float64
+	y float64
+	z float64
+}
+
+func (v Vector3) Add(o Vector3) Vector3 {
+	return Vector3{v.x + o.x, v.y + o.y, v.z + o.z}
+}
+
+func (v Vector3) Sub(o Vector3) Vector3 {
+	return Vector3{v.x - o.x, v.y - o.y, v.z - o.z}
+}
+
+func (v Vector3) Scale(s float64) Vector3 {
+	return Vector3{v.x * s, v.y * s, v.z * s}
+}
+
+func (v Vector3) Dot(o Vector3) float64 {
+	return v.x*o.x + v.y*o.y + v.z*o.z
+}
+
+func (v Vector3) Cross(o Vector3) Vector3 {
+	return Vector3{v.y*o.z - v.z*o.y, v.z*o.x - v.x*o.z, v.x*o.y - v.y*o.x}
+}
+
+func (v Vector3) Length() float64 {
+	return math.Sqrt(v.Dot(v))
+}
+
+func (v Vector3) Normalized() Vector3 {
+	length := v.Length()
+	if length == 0 {
+		return v
+	}
+	return v.Scale(1 / length)
+}
+
+// Camera orbits around the origin at a fixed distance.
+type Camera struct {
+	yaw      float64
+	pitch    float64
+	distance float64
+
+	dragging   bool
+	lastMouseX int
+	lastMouseY int
+}
+
+// Basis returns the eye position and the right, up and forward axes of the view.
+func (c *Camera) Basis() (eye, right, up, forward Vector3) {
+	eye = Vector3{
+		c.distance * math.Cos(c.pitch) * math.Sin(c.yaw),
+		c.distance * math.Sin(c.pitch),
+		c.distance * math.Cos(c.pitch) * math.Cos(c.yaw),
+	}
+	forward = eye.Scale(-1).Normalized()
+	right = forward.Cross(Vector3{0, 1, 0}).Normalized()
+	up = right.Cross(forward)
+	return eye, right, up, forward
+}
+
+func (c *Camera) Update() {
+	x, y := ebiten.CursorPosition()
+
+	if ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+		if c.dragging {
+			c.yaw -= float64(x-c.lastMouseX) * 0.01
+			c.pitch += float64(y-c.lastMouseY) * 0.01
+			c.pitch = math.Max(-math.Pi/2+0.01, math.Min(math.Pi/2-0.01, c.pitch))
+		}
+		c.dragging = true
+	} else {
+		c.dragging = false
+	}
+	c.lastMouseX, c.lastMouseY = x, y
+
+	_, wheel := ebiten.Wheel()
+	c.distance = math.Max(boxSize/2, c.distance*math.Pow(0.9, wheel))
+}
+
+type Game3D struct {
+	position  []Vector3
+	direction []Vector3
+
+	params   Params
+	boundary BoundaryMode
+	camera   Camera
+}
+
+func (g *Game3D) SpawnBoids(n int, rng *rand.Rand) {
+	maxOffset := boxSize * 0.3
+	for i := 0; i < n; i++ {
+		g.position = append(g.position, Vector3{
+			(2*rng.Float64() - 1) * maxOffset,
+			(2*rng.Float64() - 1) * maxOffset,
+			(2*rng.Float64() - 1) * maxOffset,
+		})
+		g.direction = append(g.direction, Vector3{2*rng.Float64() - 1, 2*rng.Float64() - 1, 2*rng.Float64() - 1}.Normalized())
+	}
+}
+
+func (g *Game3D) Update() error {
+	g.camera.Update()
+	g.Step()
+
+	return nil
+}
+
+// Step applies the same separation, alignment and cohesion rules as Game.Step, in three dimensions.
+func (g *Game3D) Step() {
+	w := g.params.Genome()
+
+	for i := range g.position {
+		steerSeparation := Vector3{}
+		steerAlignment := Vector3{}
+		steerCohesion := Vector3{}
+		count := 0
+
+		for j := range g.position {
+			if i == j {
+				continue
+			}
+
+			distance := g.position[j].Sub(g.position[i]).Length()
+
+			// Separation
+			if distance < g.params.DesiredSep {
+				steerSeparation = steerSeparation.Add(g.position[i].Sub(g.position[j]).Scale(1.0 / (distance + 0.001)))
+			}
+
+			// Alignment and Cohesion
+			if distance < g.params.NeighborDist {
+				steerAlignment = steerAlignment.Add(g.direction[j])
+				steerCohesion = steerCohesion.Add(g.direction[j])
+				count++
+			}
+		}
+
+		if count > 0 {
+			steerAlignment = steerAlignment.Scale(1 / float64(count))
+			steerCohesion = steerCohesion.Scale(1 / float64(count)).Sub(g.direction[i])
+		}
+
+		g.direction[i] = g.direction[i].
+			Add(steerSeparation.Scale(w.separation)).
+			Add(steerAlignment.Scale(w.alignment)).
+			Add(steerCohesion.Scale(w.cohesion))
+	}
+
+	for i := range g.position {
+		g.direction[i] = g.direction[i].Normalized()
+		g.position[i] = g.position[i].Add(g.direction[i].Scale(boidSpeed3D))
+		g.ApplyBoundary(i)
+	}
+}
+
+func (g *Game3D) ApplyBoundary(i int) {
+	half := boxSize / 2
+	position := []*float64{&g.position[i].x, &g.position[i].y, &g.position[i].z}
+	direction := []*float64{&g.direction[i].x, &g.direction[i].y, &g.direction[i].z}
+
+	for axis := range position {
+		p, d := position[axis], direction[axis]
+		if *p > -half && *p < half {
+			continue
+		}
+
+		switch g.boundary {
+		case BoundaryReset:
+			*p = 0
+			*d *= -1
+		case BoundaryWrap:
+			*p = math.Mod(*p+half+boxSize, boxSize) - half
+		case BoundaryBounce:
+			*p = math.Max(-half, math.Min(half, *p))
+			*d *= -1
+		}
+	}
+}
+
+type projectedTriangle struct {
+	vertices [3][2]float32
+	depth    float64
+	shade    float32
+}
+
+// Project maps a world point to screen coordinates and its depth along the view axis.
+func (g *Game3D) Project(p Vector3, eye, right, up, forward Vector3) (float32, float32, float64) {
+	d := p.Sub(eye)
+	z := d.Dot(forward)
+	if z < nearPlane {
+		return 0, 0, z
+	}
+
+	focal := (_screenHeight / 2) / math.Tan(fieldOfView/2)
+	x := _screenWidth/2 + focal*d.Dot(right)/z
+	y := _screenHeight/2 - focal*d.Dot(up)/z
+	return float32(x), float32(y), z
+}
+
+// Tetrahedron returns the four corners of a boid pointing along direction, nose first.
+func Tetrahedron(position, direction Vector3) [4]Vector3 {
+	helper := Vector3{0, 1, 0}
+	if math.Abs(direction.y) > 0.9 {
+		helper = Vector3{1, 0, 0}
+	}
+	side := direction.Cross(helper).Normalized()
+	top := side.Cross(direction)
+
+	back := position.Sub(direction.Scale(boidSize3D / 2))
+	return [4]Vector3{
+		position.Add(direction.Scale(boidSize3D)),
+		back.Add(side.Scale(boidSize3D / 2)).Sub(top.Scale(boidSize3D / 3)),
+		back.Sub(side.Scale(boidSize3D / 2)).Sub(top.Scale(boidSize3D / 3)),
+		back.Add(top.Scale(boidSize3D / 2)),
+	}
+}
+
+func (g *Game3D) Draw(screen *ebiten.Image) {
+	eye, right, up, forward := g.camera.Basis()
+	light := Vector3{0.3, 1, 0.5}.Normalized()
+
+	g.DrawBox(screen, eye, right, up, forward)
+
+	// Perspective already makes distant boids smaller, so only the faces need to be depth sorted
+	faces := [][3]int{{0, 1, 2}, {0, 2, 3}, {0, 3, 1}, {1, 3, 2}}
+	triangles := []projectedTriangle{}
+
+	for i := range g.position {
+		corners := Tetrahedron(g.position[i], g.direction[i])
+
+		for _, face := range faces {
+			t := projectedTriangle{}
+			visible := true
+
+			for k, c := range face {
+				x, y, z := g.Project(corners[c], eye, right, up, forward)
+				if z < nearPlane {
+					visible = false
+					break
+				}
+				t.vertices[k] = [2]float32{x, y}
+				t.depth += z / 3
+			}
+
+			if !visible {
+				continue
+			}
+
+			a, b, c := corners[face[0]], corners[face[1]], corners[face[2]]
+			normal := b.Sub(a).Cross(c.Sub(a)).Normalized()
+			t.shade = float32(0.35 + 0.65*math.Abs(normal.Dot(light)))
+			triangles = append(triangles, t)
+		}
+	}
+
+	// Painter's algorithm: far triangles first
+	sort.Slice(triangles, func(a, b int) bool { return triangles[a].depth > triangles[b].depth })
+
+	op := &ebiten.DrawTrianglesOptions{AntiAlias: false}
+	src := whiteImage.SubImage(image.Rect(1, 1, 2, 2)).(*ebiten.Image)
+	vertices := []ebiten.Vertex{}
+	indices := []uint16{}
+
+	flush := func() {
+		if len(indices) > 0 {
+			screen.DrawTriangles(vertices, indices, src, op)
+		}
+		vertices = vertices[:0]
+		indices = indices[:0]
+	}
+
+	for _, t := range triangles {
+		// Keep indices within uint16
+		if len(vertices)+3 > math.MaxUint16 {
+			flush()
+		}
+
+		for _, v := range t.vertices {
+			indices = append(indices, uint16(len(vertices)))
+			vertices = append(vertices, ebiten.Vertex{
+				DstX:   v[0],
+				DstY:   v[1],
+				ColorR: t.shade,
+				ColorG: t.shade,
+				ColorB: t.shade,
+				ColorA: 1,
+			})
+		}
+	}
+	flush()
+
+	ebitenutil.DebugPrint(screen, fmt.Sprintf("TPS: %0.2f\nFPS: %0.2f\nDrag to orbit, scroll to zoom", ebiten.ActualTPS(), ebiten.ActualFPS()))
+}
+
+func (g *Game3D) DrawBox(screen *ebiten.Image, eye, right, up, forward Vector3) {
+	half := boxSize / 2
+	corners := [8]Vector3{}
+	for i := range corners {
+		corners[i] = Vector3{half, half, half}
+		if i&1 != 0 {
+			corners[i].x = -half
+		}
+		if i&2 != 0 {
+			corners[i].y = -half
+		}
+		if i&4 != 0 {
+			corners[i].z = -half
+		}
+	}
+
+	for a := range corners {
+		for _, bit := range []int{1, 2, 4} {
+			b := a | bit
+			if b == a {
+				continue
+			}
+
+			x0, y0, z0 := g.Project(corners[a], eye, right, up, forward)
+			x1, y1, z1 := g.Project(corners[b], eye, right, up, forward)
+			if z0 < nearPlane || z1 < nearPlane {
+				continue
+			}
+
+			vector.StrokeLine(screen, x0, y0, x1, y1, 1, color.RGBA{80, 80, 80, 255}, false)
+		}
+	}
+}
+
+func (g *Game3D) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
+	return _screenWidth, _screenHeight
+}
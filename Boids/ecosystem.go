@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"math/rand"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const (
+	startEnergy     = 60.0
+	hungryEnergy    = 50.0
+	reproduceEnergy = 120.0
+	basalDrain      = 0.02 // Energy lost every tick regardless of speed
+	speedDrain      = 0.04 // Energy lost per tick scales with speed squared
+
+	foodSpawnInterval = 20 // Ticks between new food patches
+	maxFoodPatches    = 25
+	foodPatchAmount   = 80.0
+	foodPatchRadius   = 15.0
+	foodSenseDist     = 250.0
+	biteSize          = 1.5
+
+	mutationRate  = 0.1 // Standard deviation of the relative change per gene
+	maxPopulation = 400
+
+	populationHistory = 600 // Number of ticks shown in the population plot
+)
+
+type FoodPatch struct {
+	x      float64
+	y      float64
+	amount float64
+}
+
+type Ecosystem struct {
+	food       []FoodPatch
+	population []int
+	tick       int
+}
+
+// SteerToFood returns a unit vector pointing at the nearest food patch within foodSenseDist.
+func (e *Ecosystem) SteerToFood(x, y float64) [2]float64 {
+	nearest := -1
+	nearestDist := foodSenseDist
+
+	for f := range e.food {
+		dist := math.Hypot(e.food[f].x-x, e.food[f].y-y)
+		if dist < nearestDist {
+			nearest = f
+			nearestDist = dist
+		}
+	}
+
+	if nearest == -1 || nearestDist < 0.001 {
+		return [2]float64{0, 0}
+	}
+
+	return [2]float64{(e.food[nearest].x - x) / nearestDist, (e.food[nearest].y - y) / nearestDist}
+}
+
+func (e *Ecosystem) SpawnFood() {
+	margin := 2 * foodPatchRadius
+	e.food = append(e.food, FoodPatch{
+		x:      margin + rand.Float64()*(_screenWidth-2*margin),
+		y:      margin + rand.Float64()*(_screenHeight-2*margin),
+		amount: foodPatchAmount,
+	})
+}
+
+func Mutate(genome Genome) Genome {
+	mutateGene := func(gene float64) float64 {
+		return math.Max(0, gene*(1+rand.NormFloat64()*mutationRate))
+	}
+
+	genome.separation = mutateGene(genome.separation)
+	genome.alignment = mutateGene(genome.alignment)
+	genome.cohesion = mutateGene(genome.cohesion)
+	genome.avoidance = mutateGene(genome.avoidance)
+	genome.food = mutateGene(genome.food)
+	genome.speed = math.Max(0.2, mutateGene(genome.speed))
+
+	return genome
+}
+
+// Update runs after the boids have moved: drain energy, eat, die and reproduce.
+func (e *Ecosystem) Update(g *Game) {
+	e.tick++
+
+	if e.tick%foodSpawnInterval == 0 && len(e.food) < maxFoodPatches {
+		e.SpawnFood()
+	}
+
+	for i := range g.positionX {
+		speed := g.genomes[i].speed
+		g.energy[i] -= basalDrain + speedDrain*speed*speed
+
+		for f := range e.food {
+			if e.food[f].amount <= 0 {
+				continue
+			}
+
+			if math.Hypot(e.food[f].x-g.positionX[i], e.food[f].y-g.positionY[i]) < foodPatchRadius {
+				bite := math.Min(biteSize, e.food[f].amount)
+				e.food[f].amount -= bite
+				g.energy[i] += bite
+				break
+			}
+		}
+	}
+
+	// Remove eaten patches
+	remaining := e.food[:0]
+	for _, f := range e.food {
+		if f.amount > 0 {
+			remaining = append(remaining, f)
+		}
+	}
+	e.food = remaining
+
+	// Iterate backwards so the boid swapped into slot i has already been checked
+	for i := len(g.positionX) - 1; i >= 0; i-- {
+		if g.energy[i] <= 0 {
+			g.RemoveBoid(i)
+		}
+	}
+
+	parents := len(g.positionX)
+	for i := 0; i < parents && len(g.positionX) < maxPopulation; i++ {
+		if g.energy[i] < reproduceEnergy {
+			continue
+		}
+
+		g.energy[i] /= 2
+
+		angle := rand.Float64() * 2 * math.Pi
+		g.AddBoid(
+			g.positionX[i]+math.Cos(angle)*10,
+			g.positionY[i]+math.Sin(angle)*10,
+			math.Cos(angle),
+			math.Sin(angle),
+			Mutate(g.genomes[i]),
+		)
+		g.energy[len(g.energy)-1] = g.energy[i]
+	}
+
+	e.population = append(e.population, len(g.positionX))
+	if len(e.population) > populationHistory {
+		e.population = e.population[1:]
+	}
+}
+
+func (e *Ecosystem) DrawFood(screen *ebiten.Image) {
+	for _, f := range e.food {
+		radius := foodPatchRadius * math.Sqrt(f.amount/foodPatchAmount)
+		vector.DrawFilledCircle(screen, float32(f.x), float32(f.y), float32(radius), color.RGBA{200, 150, 0, 255}, true)
+	}
+}
+
+// DrawPopulation plots the population over the last populationHistory ticks in the bottom left corner.
+func (e *Ecosystem) DrawPopulation(screen *ebiten.Image) {
+	const (
+		left   = 10.0
+		width  = 300.0
+		height = 100.0
+		bottom = _screenHeight - 10.0
+	)
+
+	vector.DrawFilledRect(screen, left, bottom-height, width, height, color.RGBA{0, 0, 0, 180}, false)
+	vector.StrokeRect(screen, left, bottom-height, width, height, 1, color.RGBA{128, 128, 128, 255}, false)
+
+	peak := 1
+	for _, p := range e.population {
+		peak = max(peak, p)
+	}
+
+	for i := 1; i < len(e.population); i++ {
+		x0 := left + width*float32(i-1)/populationHistory
+		x1 := left + width*float32(i)/populationHistory
+		y0 := bottom - height*float32(e.population[i-1])/float32(peak)
+		y1 := bottom - height*float32(e.population[i])/float32(peak)
+		vector.StrokeLine(screen, x0, y0, x1, y1, 1, color.RGBA{255, 200, 0, 255}, false)
+	}
+}
+
+// Stats returns the population and mean genome for the HUD.
+func (e *Ecosystem) Stats(g *Game) string {
+	mean := Genome{}
+	for _, genome := range g.genomes {
+		mean.separation += genome.separation
+		mean.alignment += genome.alignment
+		mean.cohesion += genome.cohesion
+		mean.food += genome.food
+		mean.speed += genome.speed
+	}
+
+	if n := float64(len(g.genomes)); n > 0 {
+		mean.separation /= n
+		mean.alignment /= n
+		mean.cohesion /= n
+		mean.food /= n
+		mean.speed /= n
+	}
+
+	return fmt.Sprintf("\nPopulation: %d\nFood patches: %d\nMean separation: %0.3f\nMean alignment: %0.3f\nMean cohesion: %0.3f\nMean food: %0.3f\nMean speed: %0.3f",
+		len(g.positionX), len(e.food), mean.separation, mean.alignment, mean.cohesion, mean.food, mean.speed)
+}
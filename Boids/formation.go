@@ -0,0 +1,216 @@
+package main
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+type Formation uint8
+
+const (
+	FormationV      Formation = iota // Slots trail behind on both sides of the leader
+	FormationLine                    // Slots abreast of the leader
+	FormationColumn                  // Slots in single file behind the leader
+)
+
+var formationNames = map[string]Formation{
+	"v":      FormationV,
+	"line":   FormationLine,
+	"column": FormationColumn,
+}
+
+const (
+	formationWeight  = 1.0
+	slotSpacing      = 50.0
+	arrivalRadius    = 60.0 // Followers within this distance of their slot slow down to the leader's speed
+	maxFollowerSpeed = 2.5
+	waypointRadius   = 20.0
+	leaderTurnRate   = 0.05
+)
+
+type Leader struct {
+	boid      int
+	formation Formation
+	followers []int
+	speed     float64
+
+	// Leaders without a path are steered with the arrow keys
+	path     [][2]float64
+	waypoint int
+}
+
+// AddLeader makes boid i a leader. It stops following the flock rules and any formation it was part of.
+func (g *Game) AddLeader(i int, formation Formation) *Leader {
+	g.RemoveLeader(i)
+	g.Unfollow(i)
+
+	l := &Leader{boid: i, formation: formation, speed: g.genomes[i].speed}
+	g.leaders = append(g.leaders, l)
+	return l
+}
+
+// RemoveLeader turns boid i back into a regular boid and releases its followers.
+func (g *Game) RemoveLeader(i int) {
+	for k, l := range g.leaders {
+		if l.boid == i {
+			g.leaders = append(g.leaders[:k], g.leaders[k+1:]...)
+			return
+		}
+	}
+}
+
+func (g *Game) LeaderOf(i int) *Leader {
+	for _, l := range g.leaders {
+		if l.boid == i {
+			return l
+		}
+	}
+	return nil
+}
+
+// Follow assigns boid i to the next free slot of leader's formation.
+func (g *Game) Follow(i int, leader int) {
+	l := g.LeaderOf(leader)
+	if l == nil || i == leader || g.LeaderOf(i) != nil {
+		return
+	}
+
+	g.Unfollow(i)
+	l.followers = append(l.followers, i)
+}
+
+func (g *Game) Unfollow(i int) {
+	for _, l := range g.leaders {
+		for k, f := range l.followers {
+			if f == i {
+				l.followers = append(l.followers[:k], l.followers[k+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// SetFormation changes the slot template of leader at runtime, followers keep their slot order.
+func (g *Game) SetFormation(leader int, formation Formation) {
+	if l := g.LeaderOf(leader); l != nil {
+		l.formation = formation
+	}
+}
+
+// SlotOffset returns the offset of slot k relative to a leader heading along (hx, hy).
+func SlotOffset(formation Formation, k int, hx, hy float64) (float64, float64) {
+	// Unit vector to the leader's right
+	rx, ry := -hy, hx
+
+	side := 1.0
+	if k%2 == 1 {
+		side = -1
+	}
+	rank := float64(k/2 + 1)
+
+	switch formation {
+	case FormationV:
+		return (-hx*rank + rx*side*rank) * slotSpacing, (-hy*rank + ry*side*rank) * slotSpacing
+	case FormationLine:
+		return rx * side * rank * slotSpacing, ry * side * rank * slotSpacing
+	default:
+		return -hx * float64(k+1) * slotSpacing, -hy * float64(k+1) * slotSpacing
+	}
+}
+
+// UpdateFormations steers path-following leaders and works out the slot target and speed of every follower.
+func (g *Game) UpdateFormations() {
+	g.slotTargets = map[int][2]float64{}
+	g.speeds = map[int]float64{}
+
+	for _, l := range g.leaders {
+		if len(l.path) > 0 {
+			target := l.path[l.waypoint]
+			dx := target[0] - g.positionX[l.boid]
+			dy := target[1] - g.positionY[l.boid]
+
+			if math.Hypot(dx, dy) < waypointRadius {
+				l.waypoint = (l.waypoint + 1) % len(l.path)
+			}
+
+			g.TurnTowards(l.boid, math.Atan2(dy, dx))
+		}
+
+		g.speeds[l.boid] = l.speed
+
+		for k, f := range l.followers {
+			ox, oy := SlotOffset(l.formation, k, g.directionX[l.boid], g.directionY[l.boid])
+			target := [2]float64{g.positionX[l.boid] + ox, g.positionY[l.boid] + oy}
+			g.slotTargets[f] = target
+
+			// Arrival: far behind means catching up, on the slot means matching the leader
+			distance := math.Hypot(target[0]-g.positionX[f], target[1]-g.positionY[f])
+			g.speeds[f] = math.Min(maxFollowerSpeed, l.speed+g.genomes[f].speed*distance/arrivalRadius)
+		}
+	}
+}
+
+// TurnTowards rotates boid i's heading by at most leaderTurnRate towards angle.
+func (g *Game) TurnTowards(i int, angle float64) {
+	heading := math.Atan2(g.directionY[i], g.directionX[i])
+	delta := math.Remainder(angle-heading, 2*math.Pi)
+	heading += math.Max(-leaderTurnRate, math.Min(leaderTurnRate, delta))
+
+	g.directionX[i] = math.Cos(heading)
+	g.directionY[i] = math.Sin(heading)
+}
+
+// SteerLeadersFromKeyboard turns leaders without a path with left/right and changes their speed with up/down.
+func (g *Game) SteerLeadersFromKeyboard() {
+	for _, l := range g.leaders {
+		if len(l.path) > 0 {
+			continue
+		}
+
+		heading := math.Atan2(g.directionY[l.boid], g.directionX[l.boid])
+		if ebiten.IsKeyPressed(ebiten.KeyArrowLeft) {
+			g.TurnTowards(l.boid, heading-leaderTurnRate)
+		}
+		if ebiten.IsKeyPressed(ebiten.KeyArrowRight) {
+			g.TurnTowards(l.boid, heading+leaderTurnRate)
+		}
+		if ebiten.IsKeyPressed(ebiten.KeyArrowUp) {
+			l.speed = math.Min(maxFollowerSpeed-0.5, l.speed+0.02)
+		}
+		if ebiten.IsKeyPressed(ebiten.KeyArrowDown) {
+			l.speed = math.Max(0, l.speed-0.02)
+		}
+	}
+}
+
+// remapFormations keeps leader and follower indices valid when RemoveBoid moves boid moved into slot removed.
+func (g *Game) remapFormations(removed, moved int) {
+	g.RemoveLeader(removed)
+	g.Unfollow(removed)
+
+	if moved == removed {
+		return
+	}
+
+	for _, l := range g.leaders {
+		if l.boid == moved {
+			l.boid = removed
+		}
+		for k := range l.followers {
+			if l.followers[k] == moved {
+				l.followers[k] = removed
+			}
+		}
+	}
+}
+
+// CirclePath returns n waypoints on a circle, used to patrol leaders that are not driven by the keyboard.
+func CirclePath(cx, cy, radius float64, n int) [][2]float64 {
+	path := make([][2]float64, n)
+	for k := range path {
+		angle := 2 * math.Pi * float64(k) / float64(n)
+		path[k] = [2]float64{cx + radius*math.Cos(angle), cy + radius*math.Sin(angle)}
+	}
+	return path
+}
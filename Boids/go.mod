@@ -1,4 +1,4 @@
-module main
+module boids
 
 go 1.21.3
 
@@ -1,15 +1,18 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"image"
 	"image/color"
 	"log"
 	"math"
 	"math/rand"
+	"net/http"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/hajimehoshi/ebiten/v2/vector"
 )
 
@@ -32,6 +35,80 @@ type Game struct {
 
 	directionX []float64
 	directionY []float64
+
+	genomes []Genome
+	energy  []float64
+
+	params    Params
+	obstacles []Obstacle
+
+	// obstacle collisions during the last Step
+	collisions int
+
+	leaders     []*Leader
+	slotTargets map[int][2]float64
+	speeds      map[int]float64
+
+	tick   int
+	paused bool
+
+	// nil unless -serve is given
+	server *Server
+
+	// nil unless the ecosystem layer is enabled
+	eco *Ecosystem
+}
+
+// Genome holds the per-boid flocking weights, so that the ecosystem can mutate and evolve them.
+type Genome struct {
+	separation float64
+	alignment  float64
+	cohesion   float64
+	avoidance  float64
+	food       float64
+	speed      float64
+}
+
+func (g *Game) AddBoid(x, y, directionX, directionY float64, genome Genome) {
+	g.positionX = append(g.positionX, x)
+	g.positionY = append(g.positionY, y)
+	g.directionX = append(g.directionX, directionX)
+	g.directionY = append(g.directionY, directionY)
+	g.genomes = append(g.genomes, genome)
+	g.energy = append(g.energy, startEnergy)
+}
+
+// RemoveBoid swaps the last boid into slot i, so order is not preserved.
+func (g *Game) RemoveBoid(i int) {
+	last := len(g.positionX) - 1
+	g.remapFormations(i, last)
+
+	g.positionX[i] = g.positionX[last]
+	g.positionY[i] = g.positionY[last]
+	g.directionX[i] = g.directionX[last]
+	g.directionY[i] = g.directionY[last]
+	g.genomes[i] = g.genomes[last]
+	g.energy[i] = g.energy[last]
+
+	g.positionX = g.positionX[:last]
+	g.positionY = g.positionY[:last]
+	g.directionX = g.directionX[:last]
+	g.directionY = g.directionY[:last]
+	g.genomes = g.genomes[:last]
+	g.energy = g.energy[:last]
+}
+
+func (g *Game) SpawnBoids(n int, rng *rand.Rand) {
+	for i := 0; i < n; i++ {
+		maxOffset := 160.0
+		g.AddBoid(
+			maxOffset+rng.Float64()*(_screenWidth-2*maxOffset),
+			maxOffset+rng.Float64()*(_screenHeight-2*maxOffset),
+			2*rng.Float64()-1,
+			2*rng.Float64()-1,
+			g.params.Genome(),
+		)
+	}
 }
 
 func (g *Game) NormalizeDirections() {
@@ -44,19 +121,56 @@ func (g *Game) NormalizeDirections() {
 }
 
 const (
-	maxForce     = 0.0001 // Maximum steering force
-	desiredSep   = 100.0  // Desired separation between boids
-	neighborDist = 200.0  // Distance to consider other boids as neighbors
+	maxForce = 0.0001 // Maximum steering force
 )
 
 func (g *Game) Update() error {
+	if g.server != nil {
+		g.server.Apply(g)
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyP) {
+		g.paused = !g.paused
+	}
+
+	g.SteerLeadersFromKeyboard()
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyTab) {
+		for _, l := range g.leaders {
+			g.SetFormation(l.boid, (l.formation+1)%(FormationColumn+1))
+		}
+	}
+
+	if !g.paused {
+		g.Step()
+	}
+
+	if g.server != nil {
+		return g.server.Publish(g)
+	}
+
+	return nil
+}
+
+// Step advances the flock by one tick without reading any input, so it can also run headless.
+func (g *Game) Step() {
+	g.tick++
+	g.collisions = 0
 	g.NormalizeDirections()
+	g.UpdateFormations()
 
 	for i := range g.positionX {
+		// Leaders are steered by UpdateFormations or the keyboard
+		if g.LeaderOf(i) != nil {
+			continue
+		}
+
 		// Initialize steering vectors to zero
 		steerSeparation := [2]float64{0, 0}
 		steerAlignment := [2]float64{0, 0}
 		steerCohesion := [2]float64{0, 0}
+		steerAvoidance := g.SteerFromObstacles(g.positionX[i], g.positionY[i])
+		steerFood := [2]float64{0, 0}
 
 		countAlignment := 0
 		countCohesion := 0
@@ -71,7 +185,7 @@ func (g *Game) Update() error {
 			distance := math.Sqrt(dx*dx + dy*dy)
 
 			// Separation
-			if distance < desiredSep {
+			if distance < g.params.DesiredSep {
 				diffX := g.positionX[i] - g.positionX[j]
 				diffY := g.positionY[i] - g.positionY[j]
 				normalizeFactor := 1.0 / (distance + 0.001) // Add small value to avoid division by zero
@@ -80,7 +194,7 @@ func (g *Game) Update() error {
 			}
 
 			// Alignment and Cohesion
-			if distance < neighborDist {
+			if distance < g.params.NeighborDist {
 				steerAlignment[0] += g.directionX[j]
 				steerAlignment[1] += g.directionY[j]
 				countAlignment++
@@ -104,9 +218,27 @@ func (g *Game) Update() error {
 			steerCohesion[1] = steerCohesion[1] - g.directionY[i]
 		}
 
-		// Combine the rules (you might want to weight these differently)
-		g.directionX[i] += (steerSeparation[0] + steerAlignment[0] + steerCohesion[0]) * 0.1
-		g.directionY[i] += (steerSeparation[1] + steerAlignment[1] + steerCohesion[1]) * 0.1
+		// Hungry boids steer towards the nearest food patch
+		if g.eco != nil && g.energy[i] < hungryEnergy {
+			steerFood = g.eco.SteerToFood(g.positionX[i], g.positionY[i])
+		}
+
+		// Followers only keep separation and head for their slot
+		w := g.genomes[i]
+		steerFormation := [2]float64{0, 0}
+		if target, ok := g.slotTargets[i]; ok {
+			dx := target[0] - g.positionX[i]
+			dy := target[1] - g.positionY[i]
+			if distance := math.Hypot(dx, dy); distance > 0.001 {
+				steerFormation = [2]float64{dx / distance * formationWeight, dy / distance * formationWeight}
+			}
+			w.alignment = 0
+			w.cohesion = 0
+		}
+
+		// Combine the rules using this boid's weights
+		g.directionX[i] += steerSeparation[0]*w.separation + steerAlignment[0]*w.alignment + steerCohesion[0]*w.cohesion + steerAvoidance[0]*w.avoidance + steerFood[0]*w.food + steerFormation[0]
+		g.directionY[i] += steerSeparation[1]*w.separation + steerAlignment[1]*w.alignment + steerCohesion[1]*w.cohesion + steerAvoidance[1]*w.avoidance + steerFood[1]*w.food + steerFormation[1]
 
 		// Limit the magnitude of direction to maxForce
 		length := math.Sqrt(g.directionX[i]*g.directionX[i] + g.directionY[i]*g.directionY[i])
@@ -120,8 +252,11 @@ func (g *Game) Update() error {
 
 	g.NormalizeDirections()
 
-	velocity := 1.0
 	for i := range g.positionX {
+		velocity := g.genomes[i].speed
+		if speed, ok := g.speeds[i]; ok {
+			velocity = speed
+		}
 		g.positionX[i] += g.directionX[i] * velocity
 		g.positionY[i] += g.directionY[i] * velocity
 
@@ -134,12 +269,16 @@ func (g *Game) Update() error {
 			g.positionY[i] = _screenHeight / 2
 			g.directionY[i] *= -1
 		}
+
+		g.ResolveObstacleCollisions(i)
 	}
 
-	return nil
+	if g.eco != nil {
+		g.eco.Update(g)
+	}
 }
 
-func GenerateVertices(x, y, directionX, directionY float64) []ebiten.Vertex {
+func GenerateVertices(x, y, directionX, directionY float64, r, g, b float32) []ebiten.Vertex {
 
 	// Calculate the rotation angle
 	theta := math.Atan2(directionY, directionX) + math.Pi/2
@@ -169,9 +308,9 @@ func GenerateVertices(x, y, directionX, directionY float64) []ebiten.Vertex {
 			DstY:   float32(y + ry),
 			SrcX:   0,
 			SrcY:   0,
-			ColorR: float32(1),
-			ColorG: float32(1),
-			ColorB: float32(1),
+			ColorR: r,
+			ColorG: g,
+			ColorB: b,
 			ColorA: 1,
 		})
 	}
@@ -184,9 +323,21 @@ func (g *Game) Draw(screen *ebiten.Image) {
 	indices := []uint16{0, 1, 2, 2, 3, 0}
 	lineLength := 50.0
 
+	g.DrawObstacles(screen)
+
+	if g.eco != nil {
+		g.eco.DrawFood(screen)
+	}
+
 	for i := range g.positionX {
+		// Leaders are drawn red
+		var r, gr, b float32 = 1, 1, 1
+		if g.LeaderOf(i) != nil {
+			gr, b = 0, 0
+		}
+
 		//log.Printf("%f %f", g.positionX[i], g.positionY[i])
-		screen.DrawTriangles(GenerateVertices(g.positionX[i], g.positionY[i], g.directionX[i], g.directionY[i]), indices, whiteImage.SubImage(image.Rect(1, 1, 2, 2)).(*ebiten.Image), op)
+		screen.DrawTriangles(GenerateVertices(g.positionX[i], g.positionY[i], g.directionX[i], g.directionY[i], r, gr, b), indices, whiteImage.SubImage(image.Rect(1, 1, 2, 2)).(*ebiten.Image), op)
 		// Assuming you are in the loop where you draw your polygons
 
 		x1 := g.positionX[i] + g.directionX[i]*lineLength
@@ -197,7 +348,17 @@ func (g *Game) Draw(screen *ebiten.Image) {
 		//vector.DrawFilledRect(screen, float32(g.positionX[i]), float32(g.positionY[i]), 1, 1, color.White, false)
 	}
 
-	ebitenutil.DebugPrint(screen, fmt.Sprintf("TPS: %0.2f\nFPS: %0.2f", ebiten.ActualTPS(), ebiten.ActualFPS()))
+	hud := fmt.Sprintf("TPS: %0.2f\nFPS: %0.2f", ebiten.ActualTPS(), ebiten.ActualFPS())
+	if g.paused {
+		hud += "\nPaused"
+	}
+
+	if g.eco != nil {
+		g.eco.DrawPopulation(screen)
+		hud += g.eco.Stats(g)
+	}
+
+	ebitenutil.DebugPrint(screen, hud)
 }
 
 func (g *Game) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
@@ -205,18 +366,123 @@ func (g *Game) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeigh
 }
 
 func main() {
+	ecosystem := flag.Bool("ecosystem", false, "enable energy, food, reproduction and death")
+	obstacles := flag.Int("obstacles", 0, "number of random circular obstacles")
+	preset := flag.String("preset", "", "load flock parameters from a preset file")
+	optimize := flag.Bool("optimize", false, "run the headless parameter optimizer instead of the window")
+	objective := flag.String("objective", "polarization", "optimizer objective: polarization, cohesion or collisions")
+	generations := flag.Int("generations", 20, "optimizer generations")
+	candidates := flag.Int("candidates", 24, "optimizer population size")
+	ticks := flag.Int("ticks", 1000, "ticks simulated per optimizer candidate")
+	boids := flag.Int("boids", 100, "number of boids")
+	out := flag.String("out", "preset.json", "preset file written by the optimizer")
+	seed := flag.Int64("seed", 1, "seed for initial conditions and obstacles; the windows pick a random one unless it is given")
+	threeD := flag.Bool("3d", false, "fly the flock in a 3D box instead of the 2D window; the ecosystem, obstacles, leaders and server are 2D only")
+	boundary := flag.String("boundary", "reset", "3D boundary mode: reset, wrap or bounce")
+	leaders := flag.Int("leaders", 0, "number of leaders; the first is steered with the arrow keys, the rest patrol a path")
+	formation := flag.String("formation", "v", "formation flown behind leaders: v, line or column")
+	serve := flag.String("serve", "", "serve flock state and accept parameter changes over HTTP on this address, e.g. localhost:8080")
+	flag.Parse()
+
+	if *optimize {
+		score, ok := objectives[*objective]
+		if !ok {
+			log.Fatalf("unknown objective %q", *objective)
+		}
+		if *candidates < 1 || *generations < 0 {
+			log.Fatal("the optimizer needs at least one candidate and no negative generations")
+		}
+
+		best := Optimize(OptimizerConfig{
+			objective:   score,
+			generations: *generations,
+			candidates:  *candidates,
+			ticks:       *ticks,
+			boids:       *boids,
+			obstacles:   *obstacles,
+			seed:        *seed,
+		})
+
+		if err := SavePreset(*out, best); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("wrote %s: %+v\n", *out, best)
+		return
+	}
+
+	// flags given on the command line, since some defaults are not zero
+	given := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	spawnSeed := rand.Int63()
+	if given["seed"] {
+		spawnSeed = *seed
+	}
+
+	params := DefaultParams()
+	if *preset != "" {
+		var err error
+		if params, err = LoadPreset(*preset); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	ebiten.SetWindowSize(_screenWidth, _screenHeight)
 	ebiten.SetWindowTitle("Hello, World!")
 
-	game := Game{}
+	if *threeD {
+		for _, name := range []string{"ecosystem", "obstacles", "leaders", "formation", "serve"} {
+			if given[name] {
+				log.Fatalf("-%s is not supported in 3D", name)
+			}
+		}
 
-	for i := 0; i < 100; i++ {
-		maxOffset := 160.0
-		game.positionX = append(game.positionX, maxOffset+rand.Float64()*(_screenWidth-2*maxOffset))
-		game.positionY = append(game.positionY, maxOffset+rand.Float64()*(_screenHeight-2*maxOffset))
+		mode, ok := boundaryModes[*boundary]
+		if !ok {
+			log.Fatalf("unknown boundary mode %q", *boundary)
+		}
+
+		game := Game3D{params: params, boundary: mode, camera: Camera{pitch: 0.4, distance: boxSize * 1.8}}
+		game.SpawnBoids(*boids, rand.New(rand.NewSource(spawnSeed)))
+
+		if err := ebiten.RunGame(&game); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	game := Game{params: params}
+	if *ecosystem {
+		game.eco = &Ecosystem{}
+	}
+
+	rng := rand.New(rand.NewSource(spawnSeed))
+	game.SpawnObstacles(*obstacles, rng)
+	game.SpawnBoids(*boids, rng)
+
+	if *leaders > 0 {
+		shape, ok := formationNames[*formation]
+		if !ok {
+			log.Fatalf("unknown formation %q", *formation)
+		}
+
+		for k := 0; k < *leaders && k < len(game.positionX); k++ {
+			l := game.AddLeader(k, shape)
+			if k > 0 {
+				l.path = CirclePath(_screenWidth/2, _screenHeight/2, 150+float64(k)*60, 12)
+			}
+		}
+
+		for i := *leaders; i < len(game.positionX); i++ {
+			game.Follow(i, i%*leaders)
+		}
+	}
 
-		game.directionX = append(game.directionX, 2*rand.Float64()-1)
-		game.directionY = append(game.directionY, 2*rand.Float64()-1)
+	if *serve != "" {
+		game.server = NewServer()
+		go func() {
+			log.Fatal(http.ListenAndServe(*serve, game.server.Handler()))
+		}()
 	}
 
 	if err := ebiten.RunGame(&game); err != nil {
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"math/rand"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const (
+	obstacleMargin    = 50.0 // Distance from the edge of an obstacle at which boids start avoiding it
+	minObstacleRadius = 20.0
+	maxObstacleRadius = 60.0
+)
+
+type Obstacle struct {
+	x      float64
+	y      float64
+	radius float64
+}
+
+func (g *Game) SpawnObstacles(n int, rng *rand.Rand) {
+	for i := 0; i < n; i++ {
+		g.obstacles = append(g.obstacles, Obstacle{
+			x:      maxObstacleRadius + rng.Float64()*(_screenWidth-2*maxObstacleRadius),
+			y:      maxObstacleRadius + rng.Float64()*(_screenHeight-2*maxObstacleRadius),
+			radius: minObstacleRadius + rng.Float64()*(maxObstacleRadius-minObstacleRadius),
+		})
+	}
+}
+
+// SteerFromObstacles pushes away from every obstacle within obstacleMargin, stronger the closer it is.
+func (g *Game) SteerFromObstacles(x, y float64) [2]float64 {
+	steer := [2]float64{0, 0}
+
+	for _, o := range g.obstacles {
+		dx := x - o.x
+		dy := y - o.y
+		distance := math.Sqrt(dx*dx + dy*dy)
+		gap := distance - o.radius
+
+		if gap < obstacleMargin && distance > 0.001 {
+			strength := 1 - math.Max(gap, 0)/obstacleMargin
+			steer[0] += dx / distance * strength
+			steer[1] += dy / distance * strength
+		}
+	}
+
+	return steer
+}
+
+// ResolveObstacleCollisions moves boid i back onto the surface of any obstacle it entered and counts the collision.
+func (g *Game) ResolveObstacleCollisions(i int) {
+	for _, o := range g.obstacles {
+		dx := g.positionX[i] - o.x
+		dy := g.positionY[i] - o.y
+		distance := math.Sqrt(dx*dx + dy*dy)
+
+		if distance >= o.radius {
+			continue
+		}
+
+		g.collisions++
+
+		if distance < 0.001 {
+			dx, dy, distance = 1, 0, 1
+		}
+
+		g.positionX[i] = o.x + dx/distance*o.radius
+		g.positionY[i] = o.y + dy/distance*o.radius
+	}
+}
+
+func (g *Game) DrawObstacles(screen *ebiten.Image) {
+	for _, o := range g.obstacles {
+		vector.DrawFilledCircle(screen, float32(o.x), float32(o.y), float32(o.radius), color.RGBA{90, 90, 90, 255}, true)
+	}
+}
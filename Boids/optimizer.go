@@ -0,0 +1,226 @@
+package main
+
+import (
+	"log"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// An Objective scores the flock after a tick, higher is better. Scores are averaged over the second half of a run.
+type Objective func(g *Game) float64
+
+var objectives = map[string]Objective{
+	"polarization": Polarization,
+	"cohesion":     Cohesion,
+	"collisions":   func(g *Game) float64 { return -float64(g.collisions) },
+}
+
+// Polarization is the length of the mean heading, 1 when every boid flies the same way.
+func Polarization(g *Game) float64 {
+	if len(g.directionX) == 0 {
+		return 0
+	}
+
+	sumX, sumY := 0.0, 0.0
+	for i := range g.directionX {
+		sumX += g.directionX[i]
+		sumY += g.directionY[i]
+	}
+
+	return math.Hypot(sumX, sumY) / float64(len(g.directionX))
+}
+
+// Cohesion is 1 minus the mean distance to the centroid relative to half the screen diagonal.
+func Cohesion(g *Game) float64 {
+	n := float64(len(g.positionX))
+	if n == 0 {
+		return 0
+	}
+
+	centerX, centerY := 0.0, 0.0
+	for i := range g.positionX {
+		centerX += g.positionX[i]
+		centerY += g.positionY[i]
+	}
+	centerX /= n
+	centerY /= n
+
+	meanDist := 0.0
+	for i := range g.positionX {
+		meanDist += math.Hypot(g.positionX[i]-centerX, g.positionY[i]-centerY)
+	}
+	meanDist /= n
+
+	return 1 - meanDist/(math.Hypot(_screenWidth, _screenHeight)/2)
+}
+
+type OptimizerConfig struct {
+	objective   Objective
+	generations int
+	candidates  int
+	ticks       int
+	boids       int
+	obstacles   int
+	seed        int64
+}
+
+// Lower and upper bound of every entry in Params.Vector.
+var paramBounds = [][2]float64{
+	{10, 200},
+	{20, 400},
+	{0, 1},
+	{0, 1},
+	{0, 1},
+	{0, 3},
+}
+
+func (p Params) Vector() []float64 {
+	return []float64{p.DesiredSep, p.NeighborDist, p.Separation, p.Alignment, p.Cohesion, p.Avoidance}
+}
+
+func ParamsFromVector(v []float64) Params {
+	return Params{
+		DesiredSep:   v[0],
+		NeighborDist: v[1],
+		Separation:   v[2],
+		Alignment:    v[3],
+		Cohesion:     v[4],
+		Avoidance:    v[5],
+	}
+}
+
+// Evaluate simulates one candidate headless. Every candidate starts from the same seeded boids and obstacles.
+func Evaluate(params Params, cfg OptimizerConfig) float64 {
+	g := &Game{params: params}
+
+	rng := rand.New(rand.NewSource(cfg.seed))
+	g.SpawnObstacles(cfg.obstacles, rng)
+	g.SpawnBoids(cfg.boids, rng)
+
+	score := 0.0
+	samples := 0
+	for tick := 0; tick < cfg.ticks; tick++ {
+		g.Step()
+
+		if tick >= cfg.ticks/2 {
+			score += cfg.objective(g)
+			samples++
+		}
+	}
+
+	if samples == 0 {
+		return 0
+	}
+
+	return score / float64(samples)
+}
+
+// EvaluateAll scores every candidate, spread over one worker per CPU.
+func EvaluateAll(population [][]float64, cfg OptimizerConfig) []float64 {
+	scores := make([]float64, len(population))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				scores[i] = Evaluate(ParamsFromVector(population[i]), cfg)
+			}
+		}()
+	}
+
+	for i := range population {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return scores
+}
+
+// Optimize runs a genetic algorithm with elitism, tournament selection, blend crossover and gaussian mutation.
+func Optimize(cfg OptimizerConfig) Params {
+	const (
+		elites         = 2
+		tournamentSize = 3
+		mutationChance = 0.2
+		mutationScale  = 0.1 // Relative to the width of the parameter's bounds
+	)
+
+	rng := rand.New(rand.NewSource(cfg.seed))
+
+	population := make([][]float64, cfg.candidates)
+	population[0] = DefaultParams().Vector()
+	for i := 1; i < cfg.candidates; i++ {
+		population[i] = make([]float64, len(paramBounds))
+		for k, b := range paramBounds {
+			population[i][k] = b[0] + rng.Float64()*(b[1]-b[0])
+		}
+	}
+
+	best := population[0]
+	bestScore := math.Inf(-1)
+
+	for generation := 0; generation < cfg.generations; generation++ {
+		scores := EvaluateAll(population, cfg)
+
+		order := make([]int, len(population))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+
+		if scores[order[0]] > bestScore {
+			bestScore = scores[order[0]]
+			best = population[order[0]]
+		}
+
+		mean := 0.0
+		for _, s := range scores {
+			mean += s
+		}
+		log.Printf("generation %d: best %0.4f, mean %0.4f, overall best %0.4f", generation, scores[order[0]], mean/float64(len(scores)), bestScore)
+
+		tournament := func() []float64 {
+			winner := rng.Intn(len(population))
+			for t := 1; t < tournamentSize; t++ {
+				if c := rng.Intn(len(population)); scores[c] > scores[winner] {
+					winner = c
+				}
+			}
+			return population[winner]
+		}
+
+		next := make([][]float64, 0, cfg.candidates)
+		for e := 0; e < elites && e < len(order); e++ {
+			next = append(next, population[order[e]])
+		}
+
+		for len(next) < cfg.candidates {
+			a, b := tournament(), tournament()
+			child := make([]float64, len(paramBounds))
+
+			for k, bound := range paramBounds {
+				blend := rng.Float64()
+				child[k] = a[k]*blend + b[k]*(1-blend)
+
+				if rng.Float64() < mutationChance {
+					child[k] += rng.NormFloat64() * mutationScale * (bound[1] - bound[0])
+				}
+
+				child[k] = math.Max(bound[0], math.Min(bound[1], child[k]))
+			}
+
+			next = append(next, child)
+		}
+
+		population = next
+	}
+
+	return ParamsFromVector(best)
+}
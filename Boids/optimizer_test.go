@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestOptimizeDeterministic(t *testing.T) {
+	cfg := OptimizerConfig{
+		objective:   Polarization,
+		generations: 3,
+		candidates:  6,
+		ticks:       40,
+		boids:       20,
+		obstacles:   2,
+		seed:        7,
+	}
+
+	first := Optimize(cfg)
+	if second := Optimize(cfg); second != first {
+		t.Fatalf("seed %d gave %+v, then %+v", cfg.seed, first, second)
+	}
+
+	for k, value := range first.Vector() {
+		if bound := paramBounds[k]; value < bound[0] || value > bound[1] {
+			t.Errorf("parameter %d is %g, outside [%g, %g]", k, value, bound[0], bound[1])
+		}
+	}
+}
+
+func TestOptimizeSingleCandidate(t *testing.T) {
+	cfg := OptimizerConfig{objective: Cohesion, generations: 2, candidates: 1, ticks: 10, boids: 10, seed: 1}
+
+	// elitism keeps the only candidate, the defaults
+	if best := Optimize(cfg); best != DefaultParams() {
+		t.Errorf("got %+v, want the defaults", best)
+	}
+}
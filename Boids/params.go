@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// Params is the tunable flock parameter vector that is stored in preset files.
+type Params struct {
+	DesiredSep   float64 `json:"desiredSep"`   // Desired separation between boids
+	NeighborDist float64 `json:"neighborDist"` // Distance to consider other boids as neighbors
+	Separation   float64 `json:"separation"`
+	Alignment    float64 `json:"alignment"`
+	Cohesion     float64 `json:"cohesion"`
+	Avoidance    float64 `json:"avoidance"`
+}
+
+func DefaultParams() Params {
+	return Params{
+		DesiredSep:   100.0,
+		NeighborDist: 200.0,
+		Separation:   0.1,
+		Alignment:    0.1,
+		Cohesion:     0.1,
+		Avoidance:    1.0,
+	}
+}
+
+// Genome returns the per-boid weights that a freshly spawned boid starts with.
+func (p Params) Genome() Genome {
+	return Genome{
+		separation: p.Separation,
+		alignment:  p.Alignment,
+		cohesion:   p.Cohesion,
+		avoidance:  p.Avoidance,
+		food:       0.5,
+		speed:      1.0,
+	}
+}
+
+func LoadPreset(path string) (Params, error) {
+	params := DefaultParams()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return params, err
+	}
+
+	err = json.Unmarshal(data, &params)
+	return params, err
+}
+
+func SavePreset(path string, params Params) error {
+	data, err := json.MarshalIndent(params, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
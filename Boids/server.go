@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+type BoidState struct {
+	X  float64 `json:"x"`
+	Y  float64 `json:"y"`
+	DX float64 `json:"dx"`
+	DY float64 `json:"dy"`
+}
+
+type Snapshot struct {
+	Tick   int         `json:"tick"`
+	Paused bool        `json:"paused"`
+	Params Params      `json:"params"`
+	Boids  []BoidState `json:"boids"`
+}
+
+// ParamChange is the body accepted by POST /params. Fields left out are not changed.
+type ParamChange struct {
+	DesiredSep   *float64 `json:"desiredSep"`
+	NeighborDist *float64 `json:"neighborDist"`
+	Separation   *float64 `json:"separation"`
+	Alignment    *float64 `json:"alignment"`
+	Cohesion     *float64 `json:"cohesion"`
+	Avoidance    *float64 `json:"avoidance"`
+	Paused       *bool    `json:"paused"`
+}
+
+// Server exposes the flock over HTTP. The game publishes into it after every tick and drains queued changes before
+// the next one, so handlers never touch Game directly.
+type Server struct {
+	mu          sync.Mutex
+	snapshot    []byte
+	pending     []ParamChange
+	subscribers map[chan []byte]struct{}
+}
+
+func NewServer() *Server {
+	return &Server{snapshot: []byte("{}"), subscribers: map[chan []byte]struct{}{}}
+}
+
+// Handler serves GET /snapshot, GET /stream (Server-Sent Events, one event per tick) and POST /params.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/snapshot", s.handleSnapshot)
+	mux.HandleFunc("/stream", s.handleStream)
+	mux.HandleFunc("/params", s.handleParams)
+	return mux
+}
+
+func (s *Server) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.mu.Lock()
+	snapshot := s.snapshot
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(snapshot)
+}
+
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	// Buffer one tick, slow clients skip ticks instead of stalling the game
+	updates := make(chan []byte, 1)
+	s.mu.Lock()
+	s.subscribers[updates] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.subscribers, updates)
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case snapshot := <-updates:
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", snapshot); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func (s *Server) handleParams(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var change ParamChange
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.pending = append(s.pending, change)
+	s.mu.Unlock()
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Apply hands over the changes posted since the last tick to the game.
+func (s *Server) Apply(g *Game) {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+
+	for _, change := range pending {
+		g.ApplyParamChange(change)
+	}
+}
+
+// Publish stores the current state for /snapshot and sends it to every /stream subscriber.
+func (s *Server) Publish(g *Game) error {
+	snapshot, err := json.Marshal(g.Snapshot())
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.snapshot = snapshot
+	for subscriber := range s.subscribers {
+		select {
+		case subscriber <- snapshot:
+		default:
+		}
+	}
+
+	return nil
+}
+
+func (g *Game) Snapshot() Snapshot {
+	snapshot := Snapshot{Tick: g.tick, Paused: g.paused, Params: g.params, Boids: make([]BoidState, len(g.positionX))}
+	for i := range g.positionX {
+		snapshot.Boids[i] = BoidState{g.positionX[i], g.positionY[i], g.directionX[i], g.directionY[i]}
+	}
+	return snapshot
+}
+
+// ApplyParamChange updates the flock parameters. Weight changes override every boid's genome.
+func (g *Game) ApplyParamChange(change ParamChange) {
+	if change.DesiredSep != nil {
+		g.params.DesiredSep = *change.DesiredSep
+	}
+	if change.NeighborDist != nil {
+		g.params.NeighborDist = *change.NeighborDist
+	}
+	if change.Paused != nil {
+		g.paused = *change.Paused
+	}
+
+	for i := range g.genomes {
+		if change.Separation != nil {
+			g.genomes[i].separation = *change.Separation
+		}
+		if change.Alignment != nil {
+			g.genomes[i].alignment = *change.Alignment
+		}
+		if change.Cohesion != nil {
+			g.genomes[i].cohesion = *change.Cohesion
+		}
+		if change.Avoidance != nil {
+			g.genomes[i].avoidance = *change.Avoidance
+		}
+	}
+
+	if change.Separation != nil {
+		g.params.Separation = *change.Separation
+	}
+	if change.Alignment != nil {
+		g.params.Alignment = *change.Alignment
+	}
+	if change.Cohesion != nil {
+		g.params.Cohesion = *change.Cohesion
+	}
+	if change.Avoidance != nil {
+		g.params.Avoidance = *change.Avoidance
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newServedGame(t *testing.T) (*Game, *httptest.Server) {
+	t.Helper()
+
+	g := &Game{params: DefaultParams(), server: NewServer()}
+	g.SpawnBoids(20, rand.New(rand.NewSource(1)))
+
+	ts := httptest.NewServer(g.server.Handler())
+	t.Cleanup(ts.Close)
+	return g, ts
+}
+
+func TestSnapshot(t *testing.T) {
+	g, ts := newServedGame(t)
+	if err := g.Update(); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.Get(ts.URL + "/snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d, want 200", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("content type %q, want application/json", ct)
+	}
+
+	var snapshot Snapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Tick != 1 || len(snapshot.Boids) != 20 || snapshot.Params != g.params {
+		t.Errorf("got tick %d with %d boids and %+v, want tick 1 with 20 boids and %+v", snapshot.Tick, len(snapshot.Boids), snapshot.Params, g.params)
+	}
+	if snapshot.Boids[3].X != g.positionX[3] || snapshot.Boids[3].DY != g.directionY[3] {
+		t.Errorf("boid 3 is %+v, want the game's state", snapshot.Boids[3])
+	}
+}
+
+func TestStream(t *testing.T) {
+	g, ts := newServedGame(t)
+
+	resp, err := http.Get(ts.URL + "/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type %q, want text/event-stream", ct)
+	}
+
+	// the handler subscribes before sending the headers, so this event is not missed
+	g.tick = 41
+	if err := g.server.Publish(g); err != nil {
+		t.Fatal(err)
+	}
+
+	events := make(chan string, 1)
+	go func() {
+		lines := bufio.NewScanner(resp.Body)
+		lines.Buffer(nil, 1<<20)
+		for lines.Scan() {
+			if data, ok := strings.CutPrefix(lines.Text(), "data: "); ok {
+				events <- data
+				return
+			}
+		}
+		close(events)
+	}()
+
+	select {
+	case data, ok := <-events:
+		if !ok {
+			t.Fatal("stream ended without an event")
+		}
+
+		var snapshot Snapshot
+		if err := json.Unmarshal([]byte(data), &snapshot); err != nil {
+			t.Fatal(err)
+		}
+		if snapshot.Tick != 41 || len(snapshot.Boids) != 20 {
+			t.Errorf("got tick %d with %d boids, want tick 41 with 20 boids", snapshot.Tick, len(snapshot.Boids))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event within 5s")
+	}
+}
+
+func TestParamsAppliedOnNextTick(t *testing.T) {
+	g, ts := newServedGame(t)
+
+	resp, err := http.Post(ts.URL+"/params", "application/json", strings.NewReader(`{"cohesion": 0.7, "paused": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("status %d, want 202", resp.StatusCode)
+	}
+
+	// queued until the game drains it
+	if g.params.Cohesion != DefaultParams().Cohesion || g.paused {
+		t.Fatal("change applied before the next tick")
+	}
+
+	if err := g.Update(); err != nil {
+		t.Fatal(err)
+	}
+
+	if g.params.Cohesion != 0.7 || !g.paused {
+		t.Errorf("got cohesion %g and paused %v, want 0.7 and true", g.params.Cohesion, g.paused)
+	}
+	for i, genome := range g.genomes {
+		if genome.cohesion != 0.7 {
+			t.Fatalf("boid %d keeps cohesion %g", i, genome.cohesion)
+		}
+	}
+
+	// fields left out keep their values, and the paused game did not step
+	if g.params.Separation != DefaultParams().Separation || g.tick != 0 {
+		t.Errorf("got separation %g at tick %d, want %g at tick 0", g.params.Separation, g.tick, DefaultParams().Separation)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	_, ts := newServedGame(t)
+
+	tests := []struct {
+		method, path, body string
+		status             int
+	}{
+		{http.MethodPost, "/snapshot", "", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/params", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/params", "{not json", http.StatusBadRequest},
+		{http.MethodPost, "/params", `{"cohesion": "high"}`, http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest(test.method, ts.URL+test.path, strings.NewReader(test.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != test.status {
+			t.Errorf("%s %s %q: status %d, want %d", test.method, test.path, test.body, resp.StatusCode, test.status)
+		}
+	}
+}
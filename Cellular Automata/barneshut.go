@@ -0,0 +1,330 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	maxTreeDepth   = 40 // Deeper nodes become leaves holding every remaining particle, which bounds coincident points
+	treeStackDepth = 4 * maxTreeDepth
+
+	defaultTheta             = 0.5
+	defaultSoftening         = 2 * cellSize
+	defaultLongRangeStrength = 10 // Long range force at the default radius, a tenth of the linear law's peak
+)
+
+// LongRangeLaw is a force without cutoff, added on top of the pair laws for every pair of particles. Its strength
+// between species a and b is the rule a-b times the source's charge, so it is linear in the source and a group of
+// particles far away can be replaced by its total charge at its center.
+type LongRangeLaw interface {
+	// Force returns the force per unit coupling at distance d, positive pulling together, softened over softening
+	// and relative to the unsoftened force at distance scale.
+	Force(d, softening, scale float64) float64
+	Name() string
+}
+
+var longRangeLaws = map[string]LongRangeLaw{
+	Gravity{}.Name():   Gravity{},
+	Gravity2D{}.Name(): Gravity2D{},
+}
+
+func LongRangeLawNames() []string {
+	names := []string{}
+	for name := range longRangeLaws {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Gravity is the softened inverse-square law of three dimensional gravity.
+type Gravity struct{}
+
+func (Gravity) Name() string { return "gravity" }
+
+func (Gravity) Force(d, softening, scale float64) float64 {
+	r2 := d*d + softening*softening
+	return scale * scale * d / (r2 * math.Sqrt(r2))
+}
+
+// Gravity2D is the softened 1/d law of gravity confined to a plane, reaching further than Gravity.
+type Gravity2D struct{}
+
+func (Gravity2D) Name() string { return "gravity-2d" }
+
+func (Gravity2D) Force(d, softening, scale float64) float64 {
+	return scale * d / (d*d + softening*softening)
+}
+
+// QuadNode is a square region of the quadtree.
+type QuadNode struct {
+	x, y, size float64 // lower corner and side
+
+	// index of the first of four children, 0 for leaves since the root is never a child
+	child int
+
+	// particles of a leaf, as a range of QuadTree.order
+	start, end int
+
+	// center of all particles in the node weighted by the magnitude of their charge, used to decide whether to open it
+	center Vector2
+}
+
+// QuadTree is a Barnes–Hut tree. Since the long range force depends on the species pair, every node keeps the total
+// charge and center of each species separately. Positive and negative charges are kept apart too, since the center
+// of charges of both signs weighted by their magnitude is not where their sum acts from.
+type QuadTree struct {
+	groups int
+	nodes  []QuadNode
+	order  []int
+
+	// indexed by node*groups + Group(species, charge)
+	charge []float64
+	weight []float64 // total magnitude of charge
+	center []Vector2
+}
+
+func (t *QuadTree) Build(g *Game) {
+	t.groups = 2 * len(g.rules)
+	t.nodes = t.nodes[:0]
+	t.charge = t.charge[:0]
+	t.weight = t.weight[:0]
+	t.center = t.center[:0]
+
+	t.order = t.order[:0]
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for i, c := range g.cells {
+		t.order = append(t.order, i)
+		minX, maxX = math.Min(minX, c.position.x), math.Max(maxX, c.position.x)
+		minY, maxY = math.Min(minY, c.position.y), math.Max(maxY, c.position.y)
+	}
+
+	if len(g.cells) == 0 {
+		return
+	}
+
+	// the root is a square around every particle, slightly enlarged so that the largest coordinates fall inside
+	size := math.Max(maxX-minX, maxY-minY)*1.0001 + 1
+	t.AddNode(minX, minY, size)
+	t.Subdivide(g, 0, 0, len(t.order), 0)
+}
+
+func (t *QuadTree) AddNode(x, y, size float64) int {
+	t.nodes = append(t.nodes, QuadNode{x: x, y: y, size: size})
+	for group := 0; group < t.groups; group++ {
+		t.charge = append(t.charge, 0)
+		t.weight = append(t.weight, 0)
+		t.center = append(t.center, Vector2{0, 0})
+	}
+	return len(t.nodes) - 1
+}
+
+// Subdivide makes node k hold order[start:end], splitting it into quadrants until one particle is left, and sums the
+// charges on the way back up.
+func (t *QuadTree) Subdivide(g *Game, k, start, end, depth int) {
+	t.nodes[k].start, t.nodes[k].end = start, end
+
+	if end-start <= 1 || depth >= maxTreeDepth {
+		for _, i := range t.order[start:end] {
+			c := g.cells[i]
+			slot := k*t.groups + Group(c.cellType, c.charge)
+			t.charge[slot] += c.charge
+			t.weight[slot] += math.Abs(c.charge)
+			t.center[slot].x += math.Abs(c.charge) * c.position.x
+			t.center[slot].y += math.Abs(c.charge) * c.position.y
+		}
+		t.FinishNode(k)
+		return
+	}
+
+	node := t.nodes[k]
+	half := node.size / 2
+	quadrant := func(i int) int {
+		q := 0
+		if g.cells[i].position.x >= node.x+half {
+			q |= 1
+		}
+		if g.cells[i].position.y >= node.y+half {
+			q |= 2
+		}
+		return q
+	}
+
+	// sort the range by quadrant, keeping the order within each
+	particles := t.order[start:end]
+	sort.SliceStable(particles, func(a, b int) bool {
+		return quadrant(particles[a]) < quadrant(particles[b])
+	})
+
+	first := len(t.nodes)
+	t.nodes[k].child = first
+	for q := 0; q < 4; q++ {
+		t.AddNode(node.x+float64(q&1)*half, node.y+float64(q>>1)*half, half)
+	}
+
+	offset := start
+	for q := 0; q < 4; q++ {
+		count := 0
+		for offset+count < end && quadrant(t.order[offset+count]) == q {
+			count++
+		}
+		t.Subdivide(g, first+q, offset, offset+count, depth+1)
+		offset += count
+	}
+
+	for q := 0; q < 4; q++ {
+		for group := 0; group < t.groups; group++ {
+			slot, childSlot := k*t.groups+group, (first+q)*t.groups+group
+			t.charge[slot] += t.charge[childSlot]
+			t.weight[slot] += t.weight[childSlot]
+			t.center[slot].x += t.center[childSlot].x * t.weight[childSlot]
+			t.center[slot].y += t.center[childSlot].y * t.weight[childSlot]
+		}
+	}
+	t.FinishNode(k)
+}
+
+// Group returns the group of a particle of species t with the given charge, negative charges following positive ones.
+func Group(t CellType, charge float64) int {
+	if charge < 0 {
+		return 2*int(t) + 1
+	}
+	return 2 * int(t)
+}
+
+// FinishNode turns the weighted position sums of node k into centers.
+func (t *QuadTree) FinishNode(k int) {
+	total := 0.0
+	center := Vector2{0, 0}
+	for group := 0; group < t.groups; group++ {
+		slot := k*t.groups + group
+		if t.weight[slot] > 0 {
+			center.x += t.center[slot].x
+			center.y += t.center[slot].y
+			total += t.weight[slot]
+			t.center[slot] = Vector2{t.center[slot].x / t.weight[slot], t.center[slot].y / t.weight[slot]}
+		}
+	}
+
+	if total > 0 {
+		t.nodes[k].center = Vector2{center.x / total, center.y / total}
+	}
+}
+
+// LongRangeForce returns the long range force on particle i, from the tree or by direct summation.
+func (g *Game) LongRangeForce(i int) Vector2 {
+	if g.longRangeDirect {
+		return g.DirectLongRangeForce(i)
+	}
+	return g.TreeLongRangeForce(i)
+}
+
+// LongRangePair returns the force a source of species s with charge q at position source exerts on particle a.
+func (g *Game) LongRangePair(a *Cell, s int, q float64, source Vector2) Vector2 {
+	direction := g.Displacement(a.position, source)
+	distance := direction.Magnitude()
+	if distance <= 0.0001 {
+		return Vector2{0, 0}
+	}
+
+	force := g.longRangeStrength * g.rules[a.cellType][s] * q * g.longRange.Force(distance, g.softening, g.attractionDistance)
+	return Vector2{direction.x / distance * force, direction.y / distance * force}
+}
+
+// DirectLongRangeForce sums the long range force on particle i over every other particle.
+func (g *Game) DirectLongRangeForce(i int) Vector2 {
+	a := g.cells[i]
+	total := Vector2{0, 0}
+	for _, b := range g.cells {
+		if b.id == a.id {
+			continue
+		}
+		f := g.LongRangePair(a, int(b.cellType), b.charge, b.position)
+		total.x += f.x
+		total.y += f.y
+	}
+	return total
+}
+
+// TreeLongRangeForce approximates the long range force on particle i with the tree. A node is replaced by the total
+// charge at the center of each species and sign once its size seen from the particle is below the opening angle
+// theta. In a periodic world every node acts from its nearest image, and nodes reaching across the far edge are always
+// opened.
+func (g *Game) TreeLongRangeForce(i int) Vector2 {
+	t := &g.tree
+	a := g.cells[i]
+	total := Vector2{0, 0}
+	if len(t.nodes) == 0 {
+		return total
+	}
+
+	stack := make([]int, 1, treeStackDepth)
+	for len(stack) > 0 {
+		k := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		node := &t.nodes[k]
+
+		if node.start == node.end {
+			continue
+		}
+
+		if node.child == 0 {
+			for _, j := range t.order[node.start:node.end] {
+				b := g.cells[j]
+				if b.id == a.id {
+					continue
+				}
+				f := g.LongRangePair(a, int(b.cellType), b.charge, b.position)
+				total.x += f.x
+				total.y += f.y
+			}
+			continue
+		}
+
+		inside := a.position.x >= node.x && a.position.x < node.x+node.size &&
+			a.position.y >= node.y && a.position.y < node.y+node.size
+		d := g.Displacement(a.position, node.center)
+
+		// the particles of a node reaching across half the world from the particle act from different images than
+		// its center, so it is never approximated
+		seam := false
+		if g.boundary == BoundaryWrap {
+			box := g.Displacement(a.position, Vector2{node.x + node.size/2, node.y + node.size/2})
+			seam = math.Abs(box.x)+node.size/2 > g.worldWidth/2 || math.Abs(box.y)+node.size/2 > g.worldHeight/2
+		}
+
+		if inside || seam || node.size >= g.theta*d.Magnitude() {
+			for q := 0; q < 4; q++ {
+				stack = append(stack, node.child+q)
+			}
+			continue
+		}
+
+		for group := 0; group < t.groups; group++ {
+			slot := k*t.groups + group
+			if t.weight[slot] > 0 {
+				f := g.LongRangePair(a, group/2, t.charge[slot], t.center[slot])
+				total.x += f.x
+				total.y += f.y
+			}
+		}
+	}
+
+	return total
+}
+
+func ParseLongRangeLaw(name string) (LongRangeLaw, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	law, ok := longRangeLaws[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown long range law %q, expected one of %s", name, strings.Join(LongRangeLawNames(), ", "))
+	}
+	return law, nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// longRangeGame returns n clumped particles of four species with random rules and charges under law, clumped rather
+// than uniform since that is what the tree has to handle in a running simulation. Charges are between 0.5 and 1.5, or
+// between -1 and 1 when mixed.
+func longRangeGame(n int, law LongRangeLaw, boundary BoundaryMode, mixed bool) *Game {
+	g := &Game{
+		worldWidth:         screenWidth,
+		worldHeight:        screenHeight,
+		attractionDistance: defaultAttractionDistance,
+		boundary:           boundary,
+		longRange:          law,
+		longRangeStrength:  1,
+		softening:          defaultSoftening,
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	g.SetSpeciesCount(4)
+	for i := range g.rules {
+		for j := range g.rules[i] {
+			g.rules[i][j] = rng.Float64()*2 - 1
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		center := Vector2{float64(i%7) * 130, float64(i%5) * 190}
+		position := Vector2{center.x + rng.NormFloat64()*40 + 100, center.y + rng.NormFloat64()*40 + 100}
+		c := g.AddCell(CellConstructor(SpawnType(g.mix, i, n), nil, position, 2))
+		c.charge = 0.5 + rng.Float64()
+		if mixed {
+			c.charge = rng.Float64()*2 - 1
+		}
+		g.ApplyBoundary(c)
+	}
+	return g
+}
+
+// treeErrors returns the RMS error of the tree's long range forces at theta and the largest error on one particle,
+// both relative to the RMS of the direct forces.
+func treeErrors(g *Game, direct []Vector2, theta float64) (float64, float64) {
+	g.theta = theta
+	g.tree.Build(g)
+
+	squared, reference, worst := 0.0, 0.0, 0.0
+	for i := range g.cells {
+		tree := g.TreeLongRangeForce(i)
+		e := Vector2{tree.x - direct[i].x, tree.y - direct[i].y}
+		squared += e.x*e.x + e.y*e.y
+		reference += direct[i].x*direct[i].x + direct[i].y*direct[i].y
+		worst = math.Max(worst, e.Magnitude())
+	}
+	return math.Sqrt(squared / reference), worst / math.Sqrt(reference/float64(len(direct)))
+}
+
+func directForces(g *Game) []Vector2 {
+	direct := make([]Vector2, len(g.cells))
+	for i := range g.cells {
+		direct[i] = g.DirectLongRangeForce(i)
+	}
+	return direct
+}
+
+// longRangeCases are both laws with and without wrapping, where clumps across the edges act from their nearest images,
+// and with charges of both signs within a species.
+var longRangeCases = []struct {
+	law      LongRangeLaw
+	boundary BoundaryMode
+	mixed    bool
+}{
+	{Gravity{}, BoundaryWalls, false},
+	{Gravity{}, BoundaryWrap, false},
+	{Gravity2D{}, BoundaryWalls, false},
+	{Gravity2D{}, BoundaryWrap, false},
+	{Gravity{}, BoundaryWalls, true},
+	{Gravity2D{}, BoundaryWrap, true},
+}
+
+func TestBarnesHutAccuracy(t *testing.T) {
+	for _, test := range longRangeCases {
+		test := test
+		name := fmt.Sprintf("%s/%s", test.law.Name(), test.boundary)
+		if test.mixed {
+			name += "/mixed"
+		}
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			g := longRangeGame(1000, test.law, test.boundary, test.mixed)
+			direct := directForces(g)
+
+			// theta 0 opens every node down to the leaves, so only the order of the sum differs from the direct one
+			if rms, worst := treeErrors(g, direct, 0); worst > 1e-12 {
+				t.Errorf("theta 0 is off by %.3e RMS and %.3e on one particle", rms, worst)
+			}
+
+			if rms, _ := treeErrors(g, direct, defaultTheta); rms > 0.02 {
+				t.Errorf("theta %g is off by %.3e RMS, want at most 0.02", defaultTheta, rms)
+			}
+
+			previous := 0.0
+			for _, theta := range []float64{0.2, 0.5, 0.8, 1.2} {
+				rms, _ := treeErrors(g, direct, theta)
+				if rms <= previous {
+					t.Errorf("theta %g is off by %.3e RMS, no more than the smaller angle's %.3e", theta, rms, previous)
+				}
+				previous = rms
+			}
+		})
+	}
+}
+
+func BenchmarkLongRange(b *testing.B) {
+	g := longRangeGame(4000, Gravity{}, BoundaryWalls, false)
+	g.theta = defaultTheta
+
+	b.Run("tree", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			g.tree.Build(g)
+			for i := range g.cells {
+				g.TreeLongRangeForce(i)
+			}
+		}
+	})
+
+	b.Run("direct", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			directForces(g)
+		}
+	})
+}
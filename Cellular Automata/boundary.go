@@ -0,0 +1,132 @@
+package main
+
+import "math"
+
+type BoundaryMode uint8
+
+const (
+	BoundaryReset BoundaryMode = iota // Move particles that leave the world to its center (legacy behavior)
+	BoundaryWrap                      // Periodic world, forces use the minimum-image displacement
+	BoundaryWalls                     // Reflect particles off the edges
+)
+
+var boundaryModes = map[string]BoundaryMode{
+	"reset": BoundaryReset,
+	"wrap":  BoundaryWrap,
+	"walls": BoundaryWalls,
+}
+
+func (b BoundaryMode) String() string {
+	for name, mode := range boundaryModes {
+		if mode == b {
+			return name
+		}
+	}
+	return "unknown"
+}
+
+// ApplyBoundary brings a particle that left the world back inside according to g.boundary, reporting whether it had
+// left.
+func (g *Game) ApplyBoundary(c *Cell) bool {
+	outside := c.position.x < 0 || c.position.y < 0 || c.position.x > g.worldWidth || c.position.y > g.worldHeight
+	if g.boundary == BoundaryWrap {
+		// the far edges are the near ones in a periodic world
+		outside = outside || c.position.x == g.worldWidth || c.position.y == g.worldHeight
+	}
+	if !outside {
+		return false
+	}
+
+	switch g.boundary {
+	case BoundaryWrap:
+		c.position.x = Wrap(c.position.x, g.worldWidth)
+		c.position.y = Wrap(c.position.y, g.worldHeight)
+
+	case BoundaryWalls:
+		if c.position.x < 0 {
+			c.position.x = -c.position.x
+			c.velocity.x *= -1
+		}
+		if c.position.y < 0 {
+			c.position.y = -c.position.y
+			c.velocity.y *= -1
+		}
+		if c.position.x > g.worldWidth {
+			c.position.x = 2*g.worldWidth - c.position.x
+			c.velocity.x *= -1
+		}
+		if c.position.y > g.worldHeight {
+			c.position.y = 2*g.worldHeight - c.position.y
+			c.velocity.y *= -1
+		}
+
+		// a particle fast enough to cross the whole world is clamped
+		c.position.x = math.Max(0, math.Min(g.worldWidth, c.position.x))
+		c.position.y = math.Max(0, math.Min(g.worldHeight, c.position.y))
+
+	default:
+		if c.position.x < 0 {
+			c.position.x = g.worldWidth / 2
+		}
+
+		if c.position.y < 0 {
+			c.position.y = g.worldHeight / 2
+		}
+
+		if c.position.x > g.worldWidth {
+			c.position.x = g.worldWidth / 2
+		}
+
+		if c.position.y > g.worldHeight {
+			c.position.y = g.worldHeight / 2
+		}
+	}
+
+	return true
+}
+
+// Wrap maps value into [0, size).
+func Wrap(value float64, size float64) float64 {
+	value = math.Mod(value, size)
+	if value < 0 {
+		value += size
+	}
+	return value
+}
+
+// Displacement returns the vector from a to b, taking the shortest way across the edges in a periodic world.
+func (g *Game) Displacement(a Vector2, b Vector2) Vector2 {
+	d := Vector2{b.x - a.x, b.y - a.y}
+
+	if g.boundary == BoundaryWrap {
+		d.x -= g.worldWidth * math.Round(d.x/g.worldWidth)
+		d.y -= g.worldHeight * math.Round(d.y/g.worldHeight)
+	}
+
+	return d
+}
+
+// NeighborIndices returns the distinct partition indices next to and including index along an axis with count
+// partitions, wrapping around the edges in a periodic world, as the first n entries of an array so that the force loop
+// does not allocate.
+func (g *Game) NeighborIndices(index int, count int) (neighbors [3]int, n int) {
+	for d := -1; d <= 1; d++ {
+		k := index + d
+		if g.boundary == BoundaryWrap {
+			k = (k + count) % count
+		} else if k < 0 || k >= count {
+			continue
+		}
+
+		// with fewer than three partitions wrapping would visit one twice
+		duplicate := false
+		for _, existing := range neighbors[:n] {
+			duplicate = duplicate || existing == k
+		}
+		if !duplicate {
+			neighbors[n] = k
+			n++
+		}
+	}
+	return neighbors, n
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const (
+	defaultBrushRadius = 30
+	minBrushRadius     = 5
+	maxBrushRadius     = 300
+	sprayRate          = 4 // Particles added per tick while spraying
+)
+
+type BrushTool uint8
+
+const (
+	ToolSpray BrushTool = iota // Add particles of the selected species
+	ToolErase                  // Remove particles under the brush
+	ToolGrab                   // Drag the particles under the brush when it was pressed
+)
+
+var brushTools = []string{
+	ToolSpray: "spray",
+	ToolErase: "erase",
+	ToolGrab:  "grab",
+}
+
+func (t BrushTool) String() string {
+	return brushTools[t]
+}
+
+// Brush applies the selected tool around the cursor while the left mouse button is held. Q cycles the tool, the
+// number keys pick the species to spray and the mouse wheel or [ and ] resize it.
+type Brush struct {
+	tool    BrushTool
+	species int
+	radius  float64
+
+	// grabbed particles by ID, with their offset from the cursor
+	grabbed map[uint64]Vector2
+	cursor  Vector2
+}
+
+func (b *Brush) Update(g *Game) {
+	if b.radius == 0 {
+		b.radius = defaultBrushRadius
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyQ) {
+		b.tool = (b.tool + 1) % BrushTool(len(brushTools))
+	}
+
+	// 1 picks the first species, 0 the tenth
+	if !g.timeline.entering {
+		for digit, key := range digitKeys {
+			species := (digit + 9) % 10
+			if inpututil.IsKeyJustPressed(key) && species < len(g.rules) {
+				b.species = species
+			}
+		}
+	}
+	b.species = min(b.species, len(g.rules)-1)
+
+	_, wheel := ebiten.Wheel()
+	if inpututil.IsKeyJustPressed(ebiten.KeyBracketRight) {
+		wheel++
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyBracketLeft) {
+		wheel--
+	}
+	b.radius = math.Max(minBrushRadius, math.Min(maxBrushRadius, b.radius*math.Pow(1.1, wheel)))
+
+	x, y := ebiten.CursorPosition()
+	previous := b.cursor
+	b.cursor = Vector2{float64(x), float64(y)}
+
+	// the editor overlay takes the mouse while it is under the cursor
+	_, _, overEditor := g.editor.Hit(g, x, y)
+	if !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) || overEditor {
+		b.grabbed = nil
+		return
+	}
+
+	switch b.tool {
+	case ToolSpray:
+		b.Spray(g)
+	case ToolErase:
+		b.Erase(g)
+	case ToolGrab:
+		b.Grab(g, previous)
+	}
+
+	// keep the grid matching g.cells while paused, the next Step rebuilds it anyway
+	g.RebuildGrid()
+}
+
+// Under reports whether position is inside the brush.
+func (b *Brush) Under(g *Game, position Vector2) bool {
+	d := g.Displacement(b.cursor, position)
+	return d.Magnitude() < b.radius
+}
+
+// Spray adds particles at random points of the brush, drawing their masses and charges like spawned ones.
+func (b *Brush) Spray(g *Game) {
+	rng := g.Rand()
+	cellType := CellType(b.species)
+
+	for i := 0; i < sprayRate; i++ {
+		angle := rng.Float64() * 2 * math.Pi
+		distance := math.Sqrt(rng.Float64()) * b.radius
+		position := Vector2{b.cursor.x + math.Cos(angle)*distance, b.cursor.y + math.Sin(angle)*distance}
+
+		// points past an edge lie across the far edge in a periodic world and are dropped otherwise, rather than
+		// moved where the boundary would put a particle that left, like the center for reset
+		if g.boundary == BoundaryWrap {
+			position = Vector2{Wrap(position.x, g.worldWidth), Wrap(position.y, g.worldHeight)}
+		} else if position.x < 0 || position.y < 0 || position.x > g.worldWidth || position.y > g.worldHeight {
+			continue
+		}
+
+		c := g.AddCell(CellConstructor(cellType, g.colors[cellType], position, 2))
+		c.mass = SpeciesDistribution(g.masses, cellType).Sample(rng)
+		c.charge = SpeciesDistribution(g.charges, cellType).Sample(rng)
+	}
+}
+
+func (b *Brush) Erase(g *Game) {
+	g.RemoveCells(func(c *Cell) bool {
+		return b.Under(g, c.position)
+	})
+}
+
+// Grab picks up the particles under the brush when the button is pressed, then moves them with the cursor. They
+// keep the cursor's velocity, so they can be thrown.
+func (b *Brush) Grab(g *Game, previous Vector2) {
+	if b.grabbed == nil {
+		b.grabbed = map[uint64]Vector2{}
+		for _, c := range g.cells {
+			if b.Under(g, c.position) {
+				b.grabbed[c.id] = g.Displacement(b.cursor, c.position)
+			}
+		}
+	}
+
+	velocity := Vector2{(b.cursor.x - previous.x) / deltaT, (b.cursor.y - previous.y) / deltaT}
+	for _, c := range g.cells {
+		if offset, ok := b.grabbed[c.id]; ok {
+			c.position = Vector2{b.cursor.x + offset.x, b.cursor.y + offset.y}
+			c.velocity = velocity
+			g.ApplyBoundary(c)
+		}
+	}
+}
+
+// RemoveCells removes every particle for which remove returns true, keeping the order of the rest.
+func (g *Game) RemoveCells(remove func(c *Cell) bool) {
+	kept := g.cells[:0]
+	for _, c := range g.cells {
+		if remove(c) {
+			g.speciesCounts[c.cellType]--
+		} else {
+			kept = append(kept, c)
+		}
+	}
+
+	// drop the references left behind in the tail
+	for i := len(kept); i < len(g.cells); i++ {
+		g.cells[i] = nil
+	}
+	g.cells = kept
+}
+
+func (b *Brush) Draw(screen *ebiten.Image, g *Game) {
+	outline := color.Color(color.RGBA{255, 255, 255, 160})
+	if b.tool == ToolSpray {
+		outline = g.colors[min(b.species, len(g.colors)-1)]
+	}
+	vector.StrokeCircle(screen, float32(b.cursor.x), float32(b.cursor.y), float32(b.radius), 1, outline, true)
+}
+
+// Status returns the brush line of the debug text.
+func (b *Brush) Status() string {
+	return fmt.Sprintf("Brush: %s, species %d (Q tool, 1-9 species, [ ] size)", b.tool, b.species+1)
+}
@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestSprayNearCorner(t *testing.T) {
+	for _, boundary := range []BoundaryMode{BoundaryReset, BoundaryWalls, BoundaryWrap} {
+		g := newPresetGame(2, 0)
+		g.boundary = boundary
+		b := Brush{species: 1, radius: 50, cursor: Vector2{10, 10}}
+
+		for tick := 0; tick < 100; tick++ {
+			b.Spray(g)
+		}
+
+		if len(g.cells) == 0 {
+			t.Fatalf("%s: nothing was sprayed", boundary)
+		}
+
+		// most of the brush lies outside the world
+		if boundary != BoundaryWrap && len(g.cells) > 100*sprayRate/2 {
+			t.Errorf("%s: %d of %d points were kept", boundary, len(g.cells), 100*sprayRate)
+		}
+		if boundary == BoundaryWrap && len(g.cells) != 100*sprayRate {
+			t.Errorf("%s: %d of %d points were kept", boundary, len(g.cells), 100*sprayRate)
+		}
+
+		for i, c := range g.cells {
+			outside := c.position.x < 0 || c.position.y < 0 || c.position.x > g.worldWidth || c.position.y > g.worldHeight
+			if outside || !b.Under(g, c.position) || c.cellType != 1 {
+				t.Fatalf("%s: particle %d of species %d landed at %v", boundary, i, c.cellType, c.position)
+			}
+		}
+	}
+}
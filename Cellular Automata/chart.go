@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"image/color"
+	"log"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const (
+	chartWidth   = 200
+	chartHeight  = 50
+	chartMargin  = 10
+	chartSpacing = 20 // Room for the label above each chart
+)
+
+// Series is a rolling window of one value per tick.
+type Series struct {
+	values []float64
+	length int
+}
+
+func (s *Series) Push(value float64) {
+	if len(s.values) == s.length {
+		copy(s.values, s.values[1:])
+		s.values = s.values[:s.length-1]
+	}
+	s.values = append(s.values, value)
+}
+
+func (s *Series) Last() float64 {
+	if len(s.values) == 0 {
+		return 0
+	}
+	return s.values[len(s.values)-1]
+}
+
+// Charts records the total kinetic energy, mean speed, particles per species and boundary hits of every tick, drawn
+// as rolling line charts while shown and optionally streamed to a CSV file. G toggles the charts.
+type Charts struct {
+	visible bool
+	length  int // ticks shown, no recording when 0
+
+	kinetic Series
+	speed   Series
+	hits    Series
+	counts  []Series
+
+	file    *os.File
+	csv     *csv.Writer
+	columns int // species in the last header written
+}
+
+func NewCharts(length int) Charts {
+	return Charts{
+		length:  length,
+		kinetic: Series{length: length},
+		speed:   Series{length: length},
+		hits:    Series{length: length},
+	}
+}
+
+// Stream writes every recorded tick to a new CSV file at path.
+func (c *Charts) Stream(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	c.file = f
+	c.csv = csv.NewWriter(f)
+	return nil
+}
+
+func (c *Charts) Close() error {
+	if c.file == nil {
+		return nil
+	}
+
+	c.csv.Flush()
+	if err := c.csv.Error(); err != nil {
+		c.file.Close()
+		return err
+	}
+	return c.file.Close()
+}
+
+// Record adds the state after a tick in which hits particles left the world. When writing the CSV file fails, the
+// error is logged once and streaming stops, leaving the simulation running.
+func (c *Charts) Record(g *Game, hits int) {
+	if c.length == 0 && c.csv == nil {
+		return
+	}
+
+	kinetic := g.KineticEnergy()
+	speed := 0.0
+	for _, cell := range g.cells {
+		speed += cell.velocity.Magnitude()
+	}
+	speed /= float64(max(1, len(g.cells)))
+
+	if c.length > 0 {
+		c.kinetic.Push(kinetic)
+		c.speed.Push(speed)
+		c.hits.Push(float64(hits))
+
+		// the species count changed, the old lines no longer match the species
+		if len(c.counts) != len(g.speciesCounts) {
+			c.counts = make([]Series, len(g.speciesCounts))
+			for s := range c.counts {
+				c.counts[s] = Series{length: c.length}
+			}
+		}
+		for s, count := range g.speciesCounts {
+			c.counts[s].Push(float64(count))
+		}
+	}
+
+	if c.csv == nil {
+		return
+	}
+
+	// a new header starts every block with a different species count
+	if c.columns != len(g.speciesCounts) {
+		header := []string{"tick", "kinetic", "speed", "hits"}
+		for s := range g.speciesCounts {
+			header = append(header, fmt.Sprintf("species%d", s+1))
+		}
+		c.csv.Write(header)
+		c.columns = len(g.speciesCounts)
+	}
+
+	row := []string{
+		strconv.Itoa(g.tick),
+		strconv.FormatFloat(kinetic, 'g', 8, 64),
+		strconv.FormatFloat(speed, 'g', 8, 64),
+		strconv.Itoa(hits),
+	}
+	for _, count := range g.speciesCounts {
+		row = append(row, strconv.Itoa(count))
+	}
+	c.csv.Write(row)
+
+	// flushed every tick so the file can be followed while the simulation runs
+	c.csv.Flush()
+	if err := c.csv.Error(); err != nil {
+		log.Printf("stopped streaming to %s: %v", c.file.Name(), err)
+		c.file.Close()
+		c.file, c.csv = nil, nil
+	}
+}
+
+func (c *Charts) Update() {
+	if inpututil.IsKeyJustPressed(ebiten.KeyG) {
+		c.visible = !c.visible
+	}
+}
+
+func (c *Charts) Draw(screen *ebiten.Image, g *Game) {
+	if !c.visible || c.length == 0 {
+		return
+	}
+
+	x := float32(screen.Bounds().Dx() - chartWidth - chartMargin)
+	y := float32(chartMargin + chartSpacing)
+	white := color.RGBA{255, 255, 255, 255}
+
+	charts := []struct {
+		label  string
+		series []Series
+		colors []color.Color
+	}{
+		{fmt.Sprintf("kinetic energy %.4g", c.kinetic.Last()), []Series{c.kinetic}, []color.Color{white}},
+		{fmt.Sprintf("mean speed %.4g", c.speed.Last()), []Series{c.speed}, []color.Color{white}},
+		{"particles per species", c.counts, g.colors},
+		{fmt.Sprintf("boundary hits %.0f", c.hits.Last()), []Series{c.hits}, []color.Color{white}},
+	}
+
+	for _, chart := range charts {
+		DrawChart(screen, x, y, chart.series, chart.colors, c.length)
+		ebitenutil.DebugPrintAt(screen, chart.label, int(x), int(y)-chartSpacing+2)
+		y += chartHeight + chartSpacing
+	}
+}
+
+// DrawChart draws every series as a line in its color on a shared vertical scale from the smallest to the largest
+// value shown, with length ticks across the chart's width.
+func DrawChart(screen *ebiten.Image, x, y float32, series []Series, colors []color.Color, length int) {
+	vector.DrawFilledRect(screen, x, y, chartWidth, chartHeight, color.RGBA{0, 0, 0, 160}, false)
+	vector.StrokeRect(screen, x, y, chartWidth, chartHeight, 1, color.RGBA{255, 255, 255, 80}, false)
+
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, s := range series {
+		for _, v := range s.values {
+			low, high = math.Min(low, v), math.Max(high, v)
+		}
+	}
+	if high <= low {
+		// a flat line sits in the middle
+		low, high = low-1, high+1
+	}
+
+	step := float32(chartWidth) / float32(max(1, length-1))
+	for k, s := range series {
+		lineColor := colors[min(k, len(colors)-1)]
+		for i := 1; i < len(s.values); i++ {
+			y0 := y + chartHeight - float32((s.values[i-1]-low)/(high-low))*chartHeight
+			y1 := y + chartHeight - float32((s.values[i]-low)/(high-low))*chartHeight
+			vector.StrokeLine(screen, x+float32(i-1)*step, y0, x+float32(i)*step, y1, 1, lineColor, true)
+		}
+	}
+}
+
+// Status returns the chart line of the debug text.
+func (c *Charts) Status() string {
+	if c.file != nil {
+		return fmt.Sprintf("Charts: G to toggle, streaming to %s", c.file.Name())
+	}
+	return "Charts: G to toggle"
+}
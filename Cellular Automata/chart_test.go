@@ -0,0 +1,44 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordWithoutCharts(t *testing.T) {
+	g := newPresetGame(2, 0)
+	g.SpawnCells(20, g.Rand())
+	g.charts = NewCharts(0)
+
+	for tick := 0; tick < 5; tick++ {
+		g.Step()
+	}
+	if len(g.charts.kinetic.values) != 0 || len(g.charts.counts) != 0 {
+		t.Errorf("charts of length 0 recorded %d ticks", len(g.charts.kinetic.values))
+	}
+}
+
+func TestRecordStopsStreamingOnError(t *testing.T) {
+	g := newPresetGame(2, 0)
+	g.SpawnCells(20, g.Rand())
+	g.charts = NewCharts(10)
+	if err := g.charts.Stream(filepath.Join(t.TempDir(), "charts.csv")); err != nil {
+		t.Fatal(err)
+	}
+
+	// every write fails from now on
+	g.charts.file.Close()
+	for tick := 0; tick < 3; tick++ {
+		g.Step()
+	}
+
+	if g.charts.csv != nil || g.charts.file != nil {
+		t.Error("still streaming after a failed write")
+	}
+	if len(g.charts.kinetic.values) != 3 {
+		t.Errorf("charts hold %d ticks after a failed write, want 3", len(g.charts.kinetic.values))
+	}
+	if err := g.charts.Close(); err != nil {
+		t.Errorf("closing the stopped stream: %v", err)
+	}
+}
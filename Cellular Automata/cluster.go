@@ -0,0 +1,320 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// Cluster is a group of particles linked by chains of neighbors closer than the link distance. Positions are
+// unwrapped around the cluster's first particle, so a cluster across the edge of a periodic world stays in one piece.
+type Cluster struct {
+	id      int
+	members []int // indices into g.cells when the clusters were found
+	species []int // member count per species
+
+	mass     float64
+	centroid Vector2
+	velocity Vector2 // of the center of mass
+
+	// mean angular velocity around the centroid in radians per unit time, positive turning from +x towards +y
+	rotation float64
+
+	hull []Vector2
+}
+
+// ClusterTracker finds clusters while the overlay is shown and keeps their IDs across frames by matching each
+// cluster to the previous one whose centroid, moved on by its velocity, is closest. C toggles the overlay and
+// shift+C prints a report.
+type ClusterTracker struct {
+	visible      bool
+	linkDistance float64
+	minSize      int
+
+	clusters []Cluster
+	tick     int // when clusters were found
+	nextID   int
+}
+
+// unionFind is a disjoint set forest over particle indices.
+type unionFind []int
+
+func (u unionFind) Find(i int) int {
+	for u[i] != i {
+		u[i] = u[u[i]]
+		i = u[i]
+	}
+	return i
+}
+
+func (u unionFind) Union(a, b int) {
+	a, b = u.Find(a), u.Find(b)
+
+	// the smaller root wins, which keeps the result independent of the order of unions
+	if a < b {
+		u[b] = a
+	} else if b < a {
+		u[a] = b
+	}
+}
+
+// FindClusters links every pair of particles closer than link and returns the connected groups of at least minSize
+// particles, largest first. Since neighbors are looked up in the spatial grid, link is limited to its radius.
+func (g *Game) FindClusters(link float64, minSize int) []Cluster {
+	g.RebuildGrid()
+	link = math.Min(link, g.grid.radius)
+
+	sets := make(unionFind, len(g.cells))
+	for i := range sets {
+		sets[i] = i
+	}
+
+	for i, a := range g.cells {
+		column, row := g.grid.Index(a.position)
+		rows, rowCount := g.NeighborIndices(row, g.grid.rows)
+		columns, columnCount := g.NeighborIndices(column, g.grid.columns)
+		for _, neighborRow := range rows[:rowCount] {
+			for _, neighborColumn := range columns[:columnCount] {
+				for _, j := range g.grid.Partition(neighborColumn, neighborRow) {
+					if j <= i {
+						continue
+					}
+					d := g.Displacement(a.position, g.cells[j].position)
+					if d.Magnitude() < link {
+						sets.Union(i, j)
+					}
+				}
+			}
+		}
+	}
+
+	groups := map[int][]int{}
+	roots := []int{}
+	for i := range g.cells {
+		root := sets.Find(i)
+		if groups[root] == nil {
+			roots = append(roots, root)
+		}
+		groups[root] = append(groups[root], i)
+	}
+
+	clusters := []Cluster{}
+	for _, root := range roots {
+		if len(groups[root]) >= minSize {
+			clusters = append(clusters, g.MeasureCluster(groups[root]))
+		}
+	}
+
+	sort.SliceStable(clusters, func(a, b int) bool {
+		return len(clusters[a].members) > len(clusters[b].members)
+	})
+
+	return clusters
+}
+
+// MeasureCluster computes the composition, motion and hull of a group of particles.
+func (g *Game) MeasureCluster(members []int) Cluster {
+	c := Cluster{members: members, species: make([]int, len(g.rules))}
+
+	anchor := g.cells[members[0]].position
+	positions := make([]Vector2, len(members))
+
+	momentum := Vector2{0, 0}
+	for k, i := range members {
+		p := g.cells[i]
+		c.species[p.cellType]++
+
+		d := g.Displacement(anchor, p.position)
+		positions[k] = Vector2{anchor.x + d.x, anchor.y + d.y}
+
+		c.mass += p.mass
+		c.centroid.x += p.mass * positions[k].x
+		c.centroid.y += p.mass * positions[k].y
+		momentum.x += p.mass * p.velocity.x
+		momentum.y += p.mass * p.velocity.y
+	}
+
+	c.centroid = Vector2{c.centroid.x / c.mass, c.centroid.y / c.mass}
+	c.velocity = Vector2{momentum.x / c.mass, momentum.y / c.mass}
+
+	// angular momentum over moment of inertia, both around the centroid
+	angularMomentum, inertia := 0.0, 0.0
+	for k, i := range members {
+		p := g.cells[i]
+		r := Vector2{positions[k].x - c.centroid.x, positions[k].y - c.centroid.y}
+		v := Vector2{p.velocity.x - c.velocity.x, p.velocity.y - c.velocity.y}
+		angularMomentum += p.mass * (r.x*v.y - r.y*v.x)
+		inertia += p.mass * (r.x*r.x + r.y*r.y)
+	}
+	if inertia > 0 {
+		c.rotation = angularMomentum / inertia
+	}
+
+	c.hull = ConvexHull(positions)
+	return c
+}
+
+// ConvexHull returns the hull of points in counterclockwise order, by Andrew's monotone chain.
+func ConvexHull(points []Vector2) []Vector2 {
+	sorted := append([]Vector2{}, points...)
+	sort.Slice(sorted, func(a, b int) bool {
+		if sorted[a].x != sorted[b].x {
+			return sorted[a].x < sorted[b].x
+		}
+		return sorted[a].y < sorted[b].y
+	})
+
+	if len(sorted) < 3 {
+		return sorted
+	}
+
+	cross := func(o, a, b Vector2) float64 {
+		return (a.x-o.x)*(b.y-o.y) - (a.y-o.y)*(b.x-o.x)
+	}
+
+	hull := []Vector2{}
+
+	// lower hull, then upper hull, each dropping points that do not turn counterclockwise
+	for _, p := range sorted {
+		for len(hull) >= 2 && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+
+	lower := len(hull) + 1
+	for i := len(sorted) - 2; i >= 0; i-- {
+		p := sorted[i]
+		for len(hull) >= lower && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+
+	return hull[:len(hull)-1]
+}
+
+// Track gives each new cluster the ID of the nearest unclaimed previous cluster within twice the link distance of where
+// that cluster was heading, largest clusters choosing first. Clusters without a match get a new ID.
+func (t *ClusterTracker) Track(g *Game, clusters []Cluster) {
+	elapsed := float64(g.tick-t.tick) * deltaT
+	claimed := make([]bool, len(t.clusters))
+
+	for k := range clusters {
+		best, bestDistance := -1, 2*t.linkDistance
+		for j, previous := range t.clusters {
+			if claimed[j] {
+				continue
+			}
+
+			predicted := Vector2{previous.centroid.x + previous.velocity.x*elapsed, previous.centroid.y + previous.velocity.y*elapsed}
+			d := g.Displacement(predicted, clusters[k].centroid)
+			if distance := d.Magnitude(); distance < bestDistance {
+				best, bestDistance = j, distance
+			}
+		}
+
+		if best >= 0 {
+			claimed[best] = true
+			clusters[k].id = t.clusters[best].id
+		} else {
+			t.nextID++
+			clusters[k].id = t.nextID
+		}
+	}
+
+	t.clusters = clusters
+	t.tick = g.tick
+}
+
+// Refresh searches for clusters again if the simulation moved on since the last search.
+func (t *ClusterTracker) Refresh(g *Game) {
+	if t.tick == g.tick && t.clusters != nil {
+		return
+	}
+	t.Track(g, g.FindClusters(t.linkDistance, t.minSize))
+}
+
+func (t *ClusterTracker) Update(g *Game) {
+	if inpututil.IsKeyJustPressed(ebiten.KeyC) && !ebiten.IsKeyPressed(ebiten.KeyShift) {
+		t.visible = !t.visible
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyC) && ebiten.IsKeyPressed(ebiten.KeyShift) {
+		t.Refresh(g)
+		fmt.Print(t.Report(g))
+	}
+
+	if t.visible {
+		t.Refresh(g)
+	}
+}
+
+func (t *ClusterTracker) Draw(screen *ebiten.Image, g *Game) {
+	if !t.visible {
+		return
+	}
+
+	for _, c := range t.clusters {
+		// golden angle steps keep neighboring IDs apart in hue
+		outline := HSVToRGB(math.Mod(float64(c.id)*137.508, 360), 0.6, 1)
+
+		for k := range c.hull {
+			a, b := c.hull[k], c.hull[(k+1)%len(c.hull)]
+			vector.StrokeLine(screen, float32(a.x), float32(a.y), float32(b.x), float32(b.y), 1, outline, true)
+		}
+		ebitenutil.DebugPrintAt(screen, fmt.Sprintf("#%d", c.id), int(c.centroid.x), int(c.centroid.y))
+	}
+}
+
+// Status returns the cluster line of the debug text.
+func (t *ClusterTracker) Status() string {
+	if !t.visible {
+		return "Clusters: C to show"
+	}
+	return fmt.Sprintf("Clusters: %d (shift+C report)", len(t.clusters))
+}
+
+// Report describes the clusters: how many there are, their size distribution and each one's composition and motion.
+func (t *ClusterTracker) Report(g *Game) string {
+	var b strings.Builder
+
+	clustered := 0
+	for _, c := range t.clusters {
+		clustered += len(c.members)
+	}
+	fmt.Fprintf(&b, "tick %d: %d clusters of at least %d particles linked within %g, holding %d of %d particles\n",
+		t.tick, len(t.clusters), t.minSize, t.linkDistance, clustered, len(g.cells))
+
+	// sizes in power of two buckets
+	buckets := map[int]int{}
+	largest := 0
+	for _, c := range t.clusters {
+		bucket := 1
+		for bucket*2 <= len(c.members) {
+			bucket *= 2
+		}
+		buckets[bucket]++
+		largest = max(largest, bucket)
+	}
+	for bucket := 1; bucket <= largest; bucket *= 2 {
+		if buckets[bucket] > 0 {
+			fmt.Fprintf(&b, "  size %d-%d: %d\n", bucket, bucket*2-1, buckets[bucket])
+		}
+	}
+
+	fmt.Fprintf(&b, "%6s %6s %-24s %20s %12s\n", "id", "size", "species", "velocity", "rotation")
+	for _, c := range t.clusters {
+		species := strings.Trim(fmt.Sprint(c.species), "[]")
+		velocity := fmt.Sprintf("(%.1f, %.1f)", c.velocity.x, c.velocity.y)
+		fmt.Fprintf(&b, "%6d %6d %-24s %20s %12.3f\n", c.id, len(c.members), species, velocity, c.rotation)
+	}
+
+	return b.String()
+}
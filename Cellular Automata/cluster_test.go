@@ -0,0 +1,147 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func clusterGame(boundary BoundaryMode) *Game {
+	g := &Game{worldWidth: 1000, worldHeight: 700, attractionDistance: 100, boundary: boundary}
+	g.SetSpeciesCount(3)
+	return g
+}
+
+// addClump adds one particle of each of types on a grid 5 apart, five to a row, starting at corner.
+func addClump(g *Game, corner Vector2, types ...CellType) {
+	for k, cellType := range types {
+		position := Vector2{corner.x + float64(k%5)*5, corner.y + float64(k/5)*5}
+		g.AddCell(CellConstructor(cellType, nil, position, 2))
+	}
+}
+
+func repeat(cellType CellType, n int) []CellType {
+	types := []CellType{}
+	for i := 0; i < n; i++ {
+		types = append(types, cellType)
+	}
+	return types
+}
+
+func TestFindClustersComposition(t *testing.T) {
+	g := clusterGame(BoundaryWalls)
+	addClump(g, Vector2{200, 200}, append(repeat(0, 10), repeat(1, 5)...)...)
+	addClump(g, Vector2{700, 500}, repeat(2, 8)...)
+
+	// a stray particle is below the minimum size
+	addClump(g, Vector2{500, 100}, 1)
+
+	clusters := g.FindClusters(8, 3)
+	if len(clusters) != 2 {
+		t.Fatalf("found %d clusters, want 2", len(clusters))
+	}
+
+	if !slices.Equal(clusters[0].species, []int{10, 5, 0}) || !slices.Equal(clusters[1].species, []int{0, 0, 8}) {
+		t.Errorf("clusters have species %v and %v, want [10 5 0] and [0 0 8]", clusters[0].species, clusters[1].species)
+	}
+	// a row of five and a row of three
+	if c := clusters[1].centroid; math.Abs(c.x-708.125) > 1e-9 || math.Abs(c.y-501.875) > 1e-9 {
+		t.Errorf("second cluster is centered at %v, want (708.125, 501.875)", c)
+	}
+}
+
+func TestFindClustersAcrossSeam(t *testing.T) {
+	for _, boundary := range []BoundaryMode{BoundaryWrap, BoundaryWalls} {
+		g := clusterGame(boundary)
+		for _, x := range []float64{985, 990, 995, 0, 5, 10} {
+			g.AddCell(CellConstructor(0, nil, Vector2{x, 300}, 2))
+		}
+
+		clusters := g.FindClusters(8, 2)
+		want := map[BoundaryMode]int{BoundaryWrap: 1, BoundaryWalls: 2}[boundary]
+		if len(clusters) != want {
+			t.Fatalf("%s: found %d clusters, want %d", boundary, len(clusters), want)
+		}
+
+		// unwrapped around the first particle, the middle of the chain is on the seam
+		if boundary == BoundaryWrap && math.Abs(clusters[0].centroid.x-997.5) > 1e-9 {
+			t.Errorf("cluster across the seam is centered at x %g, want 997.5", clusters[0].centroid.x)
+		}
+	}
+}
+
+func TestConvexHullSquare(t *testing.T) {
+	points := []Vector2{{10, 10}, {5, 5}, {0, 10}, {5, 0}, {0, 0}, {2, 7}, {10, 0}, {10, 5}}
+	want := []Vector2{{0, 0}, {10, 0}, {10, 10}, {0, 10}}
+
+	if hull := ConvexHull(points); !slices.Equal(hull, want) {
+		t.Errorf("hull is %v, want %v", hull, want)
+	}
+}
+
+func TestClusterRotation(t *testing.T) {
+	const (
+		radius = 8.0
+		omega  = 0.5
+	)
+	drift := Vector2{3, -2}
+
+	g := clusterGame(BoundaryWalls)
+	center := Vector2{400, 300}
+	for k := 0; k < 12; k++ {
+		angle := float64(k) * math.Pi / 6
+		r := Vector2{radius * math.Cos(angle), radius * math.Sin(angle)}
+		c := g.AddCell(CellConstructor(0, nil, Vector2{center.x + r.x, center.y + r.y}, 2))
+		c.velocity = Vector2{drift.x - omega*r.y, drift.y + omega*r.x}
+	}
+
+	clusters := g.FindClusters(8, 2)
+	if len(clusters) != 1 {
+		t.Fatalf("found %d clusters, want 1", len(clusters))
+	}
+
+	c := clusters[0]
+	if math.Abs(c.rotation-omega) > 1e-9 {
+		t.Errorf("rotation is %g, want %g", c.rotation, omega)
+	}
+	if math.Abs(c.velocity.x-drift.x) > 1e-9 || math.Abs(c.velocity.y-drift.y) > 1e-9 {
+		t.Errorf("velocity is %v, want %v", c.velocity, drift)
+	}
+}
+
+func TestClusterTrackerKeepsIDs(t *testing.T) {
+	g := clusterGame(BoundaryWalls)
+	addClump(g, Vector2{200, 200}, repeat(0, 10)...)
+	addClump(g, Vector2{600, 400}, repeat(1, 6)...)
+	for _, c := range g.cells {
+		c.velocity = Vector2{40, -20}
+	}
+
+	tracker := ClusterTracker{linkDistance: 8, minSize: 3}
+	tracker.Track(g, g.FindClusters(tracker.linkDistance, tracker.minSize))
+	first := []int{tracker.clusters[0].id, tracker.clusters[1].id}
+	if first[0] == first[1] {
+		t.Fatalf("both clusters got ID %d", first[0])
+	}
+
+	// farther than the link distance, so only the predicted centroid matches
+	g.tick += 10
+	for _, c := range g.cells {
+		c.position.x += c.velocity.x * 10 * deltaT
+		c.position.y += c.velocity.y * 10 * deltaT
+	}
+	addClump(g, Vector2{800, 100}, repeat(2, 4)...)
+
+	tracker.Track(g, g.FindClusters(tracker.linkDistance, tracker.minSize))
+	if len(tracker.clusters) != 3 {
+		t.Fatalf("found %d clusters, want 3", len(tracker.clusters))
+	}
+
+	second := []int{tracker.clusters[0].id, tracker.clusters[1].id, tracker.clusters[2].id}
+	if second[0] != first[0] || second[1] != first[1] {
+		t.Errorf("clusters moved from IDs %v to %v", first, second[:2])
+	}
+	if second[2] == first[0] || second[2] == first[1] {
+		t.Errorf("new cluster took ID %d of an existing one", second[2])
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"slices"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const (
+	editorMargin  = 10
+	editorMaxSize = 400 // Largest width of the matrix in pixels
+	editorMaxCell = 28
+)
+
+// EditorMode selects which per species pair matrix the editor shows.
+type EditorMode uint8
+
+const (
+	EditRules EditorMode = iota
+	EditRadii
+	EditBetas
+)
+
+// editorMatrices describes each mode: its name, the range values are clamped to and how much a value changes per tick
+// while a mouse button is held on it.
+var editorMatrices = []struct {
+	name     string
+	min      float64
+	max      float64
+	rate     float64
+	defaults func(g *Game) float64
+}{
+	EditRules: {"rules", -1, 1, 0.02, func(g *Game) float64 { return 0 }},
+	EditRadii: {"radii", cellSize, maxRadius, 1, func(g *Game) float64 { return g.attractionDistance }},
+	EditBetas: {"betas", 0.05, 0.95, 0.005, func(g *Game) float64 { return defaultBeta }},
+}
+
+// RuleEditor is an overlay showing the rule, radius or beta matrix. Rows are the species being pushed, columns the
+// species pushing.
+type RuleEditor struct {
+	visible     bool
+	selectedRow int
+	mode        EditorMode
+
+	// why the last radius edit was undone, shown until the next one succeeds
+	warning string
+}
+
+func (e *RuleEditor) Matrix(g *Game) [][]float64 {
+	switch e.mode {
+	case EditRadii:
+		return g.radii
+	case EditBetas:
+		return g.betas
+	default:
+		return g.rules
+	}
+}
+
+// Layout returns the top left corner of the overlay and the size of one matrix cell for n species.
+func (e *RuleEditor) Layout(g *Game) (float32, float32, float32) {
+	n := float32(len(g.rules))
+	size := float32(math.Min(editorMaxCell, editorMaxSize/float64(n+1)))
+	x := float32(g.worldWidth) - editorMargin - (n+1)*size
+	return x, editorMargin, size
+}
+
+// Hit returns the matrix row and column under the screen position, -1 meaning the swatch row or column.
+func (e *RuleEditor) Hit(g *Game, x, y int) (int, int, bool) {
+	if !e.visible {
+		return 0, 0, false
+	}
+
+	left, top, size := e.Layout(g)
+	column := int(math.Floor(float64((float32(x)-left)/size))) - 1
+	row := int(math.Floor(float64((float32(y)-top)/size))) - 1
+
+	n := len(g.rules)
+	if row < -1 || row >= n || column < -1 || column >= n {
+		return 0, 0, false
+	}
+	return row, column, true
+}
+
+func (e *RuleEditor) Update(g *Game) {
+	if inpututil.IsKeyJustPressed(ebiten.KeyE) {
+		e.visible = !e.visible
+	}
+
+	if !e.visible {
+		return
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyM) {
+		e.mode = (e.mode + 1) % EditorMode(len(editorMatrices))
+	}
+
+	e.selectedRow = min(e.selectedRow, len(g.rules)-1)
+	matrix := e.Matrix(g)
+	bounds := editorMatrices[e.mode]
+
+	// radius edits that leave a reaction reaching further than the grid are undone
+	if e.mode == EditRadii {
+		saved := ResizeMatrix(g.radii, len(g.radii), func() float64 { return 0 })
+		defer func() {
+			if err := g.CheckReactions(); err != nil {
+				g.radii = saved
+				e.warning = err.Error()
+			} else if !slices.EqualFunc(g.radii, saved, slices.Equal[[]float64]) {
+				e.warning = ""
+			}
+		}()
+	}
+
+	x, y := ebiten.CursorPosition()
+	row, column, ok := e.Hit(g, x, y)
+	if ok && row >= 0 && column >= 0 {
+		if ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+			matrix[row][column] = math.Min(bounds.max, matrix[row][column]+bounds.rate)
+		}
+		if ebiten.IsMouseButtonPressed(ebiten.MouseButtonRight) {
+			matrix[row][column] = math.Max(bounds.min, matrix[row][column]-bounds.rate)
+		}
+	}
+
+	// L cycles the force law of the pair under the cursor
+	if ok && row >= 0 && column >= 0 && inpututil.IsKeyJustPressed(ebiten.KeyL) {
+		g.laws[row][column] = NextForceLaw(g.laws[row][column])
+	}
+
+	// clicking a swatch on the left selects that row
+	if ok && column == -1 && row >= 0 && inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		e.selectedRow = row
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyArrowUp) {
+		e.selectedRow = (e.selectedRow + len(g.rules) - 1) % len(g.rules)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyArrowDown) {
+		e.selectedRow = (e.selectedRow + 1) % len(g.rules)
+	}
+
+	selected := matrix[e.selectedRow]
+
+	// Z resets (zero for rules), N negates rules, Y mirrors the row into its column, shift+R randomizes it
+	if inpututil.IsKeyJustPressed(ebiten.KeyZ) {
+		for j := range selected {
+			selected[j] = bounds.defaults(g)
+		}
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyN) && e.mode == EditRules {
+		for j := range selected {
+			selected[j] *= -1
+		}
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyY) {
+		for j := range selected {
+			matrix[j][e.selectedRow] = selected[j]
+		}
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyR) && ebiten.IsKeyPressed(ebiten.KeyShift) {
+		for j := range selected {
+			selected[j] = bounds.min + g.Rand().Float64()*(bounds.max-bounds.min)
+		}
+	}
+}
+
+// RuleColor maps attraction to green and repulsion to red.
+func RuleColor(rule float64) color.RGBA {
+	intensity := uint8(math.Min(1, math.Abs(rule)) * 255)
+	if rule >= 0 {
+		return color.RGBA{0, intensity, 0, 255}
+	}
+	return color.RGBA{intensity, 0, 0, 255}
+}
+
+// CellColor returns the color of a matrix entry in the current mode. Radii and betas are shown in blue, brighter
+// meaning larger.
+func (e *RuleEditor) CellColor(value float64) color.RGBA {
+	if e.mode == EditRules {
+		return RuleColor(value)
+	}
+
+	bounds := editorMatrices[e.mode]
+	intensity := uint8((value - bounds.min) / (bounds.max - bounds.min) * 255)
+	return color.RGBA{intensity / 3, intensity / 2, intensity, 255}
+}
+
+func (e *RuleEditor) Draw(screen *ebiten.Image, g *Game) {
+	if !e.visible {
+		return
+	}
+
+	left, top, size := e.Layout(g)
+	n := len(g.rules)
+	matrix := e.Matrix(g)
+
+	vector.DrawFilledRect(screen, left, top, float32(n+1)*size, float32(n+1)*size, color.RGBA{20, 20, 20, 220}, false)
+
+	for i := 0; i < n; i++ {
+		offset := float32(i+1) * size
+
+		// swatches: the row species on the left, the column species on top
+		vector.DrawFilledRect(screen, left+2, top+offset+2, size-4, size-4, g.colors[i], false)
+		vector.DrawFilledRect(screen, left+offset+2, top+2, size-4, size-4, g.colors[i], false)
+
+		for j := 0; j < n; j++ {
+			vector.DrawFilledRect(screen, left+float32(j+1)*size+1, top+offset+1, size-2, size-2, e.CellColor(matrix[i][j]), false)
+		}
+	}
+
+	vector.StrokeRect(screen, left, top+float32(e.selectedRow+1)*size, float32(n+1)*size, size, 2, color.RGBA{255, 255, 0, 255}, false)
+
+	help := "M: " + editorMatrices[e.mode].name + "\nLMB/RMB raise/lower, L law, up/down select row\nZ reset, N negate, Y symmetrize, shift+R randomize row"
+	x, y := ebiten.CursorPosition()
+	if row, column, ok := e.Hit(g, x, y); ok && row >= 0 && column >= 0 {
+		help = fmt.Sprintf("%s[%d][%d] = %0.2f, %s\n", editorMatrices[e.mode].name, row, column, matrix[row][column], g.laws[row][column].Name()) + help
+	}
+	if e.mode == EditRadii && e.warning != "" {
+		help += "\n" + e.warning
+	}
+	textLeft := min(left, float32(g.worldWidth)-editorMargin-330)
+	ebitenutil.DebugPrintAt(screen, help, int(textLeft), int(top+float32(n+1)*size)+4)
+}
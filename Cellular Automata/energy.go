@@ -0,0 +1,60 @@
+package main
+
+const potentialSegments = 2000 // Simpson segments used to integrate the force into a potential
+
+// PairPotential returns the potential energy of two particles of species a and b at distance d, zero at the edge of
+// their radius. It is the integral of the pair force, which pulls the particles together where it is positive.
+func (g *Game) PairPotential(a, b CellType, d float64) float64 {
+	radius := g.radii[a][b]
+	if d >= radius {
+		return 0
+	}
+
+	force := func(s float64) float64 {
+		return g.Force(a, b, s)
+	}
+
+	// U(d) = -integral of F from d to the radius, by Simpson's rule
+	h := (radius - d) / potentialSegments
+	sum := force(d) + force(radius)
+	for k := 1; k < potentialSegments; k++ {
+		weight := 2.0
+		if k%2 == 1 {
+			weight = 4
+		}
+		sum += weight * force(d+float64(k)*h)
+	}
+	return -sum * h / 3
+}
+
+// Energy returns the kinetic plus pair potential energy of every particle. Since each particle of a pair feels the
+// force scaled by the other's charge, energy is only conserved when the charges of every interacting pair are equal,
+// and the potential uses their mean.
+func (g *Game) Energy() float64 {
+	energy := g.KineticEnergy()
+	for i, a := range g.cells {
+		for _, b := range g.cells[i+1:] {
+			d := g.Displacement(a.position, b.position)
+			energy += g.PairPotential(a.cellType, b.cellType, d.Magnitude()) * (a.charge + b.charge) / 2
+		}
+	}
+	return energy
+}
+
+func (g *Game) KineticEnergy() float64 {
+	energy := 0.0
+	for _, c := range g.cells {
+		energy += 0.5 * c.mass * (c.velocity.x*c.velocity.x + c.velocity.y*c.velocity.y)
+	}
+	return energy
+}
+
+// Momentum returns the magnitude of the total momentum, which is conserved under the same conditions as energy.
+func (g *Game) Momentum() float64 {
+	momentum := Vector2{0, 0}
+	for _, c := range g.cells {
+		momentum.x += c.mass * c.velocity.x
+		momentum.y += c.mass * c.velocity.y
+	}
+	return momentum.Magnitude()
+}
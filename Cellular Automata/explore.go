@@ -0,0 +1,307 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	thumbnailSize = 256 // Width of the candidate thumbnails in pixels
+	entropyBins   = 16  // Spatial entropy counts particles in entropyBins x entropyBins regions
+
+	// kinetic energy per particle at which the activity score reaches one half
+	activityScale = 500
+)
+
+// Candidate is one random rule set tried by the explorer, with the metrics of its run.
+type Candidate struct {
+	index  int
+	preset Preset
+
+	kinetic     float64 // mean kinetic energy per particle over the last tenth of the run
+	clusters    int
+	entropy     float64 // of the particle distribution over the world, from 0 for one spot to 1 for uniform
+	persistence float64 // mean cosine between each particle's displacements over the last two tenths of the run
+	score       float64
+
+	thumbnail *image.RGBA
+}
+
+// Score combines the metrics into one number between 0 and 1. Interesting worlds form several clusters rather than a
+// uniform gas or one blob, keep moving, and move the same way over time rather than jittering.
+func (c *Candidate) Score() float64 {
+	clusters := math.Min(1, math.Log1p(float64(c.clusters))/math.Log1p(50))
+	structure := 1 - c.entropy
+	activity := c.kinetic / (c.kinetic + activityScale)
+	persistence := math.Max(0, c.persistence)
+
+	return 0.3*clusters + 0.2*structure + 0.2*activity + 0.3*persistence
+}
+
+// NewCandidateGame returns a game with g's world and settings running preset p, spawned like main spawns a preset,
+// so loading the preset with -preset and the same -cells and world flags reproduces the run.
+func (g *Game) NewCandidateGame(p Preset, cells int) (*Game, error) {
+	c := &Game{
+		worldWidth:         g.worldWidth,
+		worldHeight:        g.worldHeight,
+		attractionDistance: g.attractionDistance,
+		halfLife:           g.halfLife,
+		substeps:           g.substeps,
+		integrator:         g.integrator,
+		defaultLaw:         g.defaultLaw,
+		boundary:           g.boundary,
+		mix:                append([]float64{}, g.mix...),
+		masses:             g.masses,
+		charges:            g.charges,
+		clusters:           ClusterTracker{linkDistance: g.clusters.linkDistance, minSize: g.clusters.minSize},
+		workers:            1,
+	}
+
+	if err := c.ApplyPreset(p); err != nil {
+		return nil, err
+	}
+
+	c.Seed(c.seed)
+	c.SpawnCells(cells, c.rng)
+	return c, nil
+}
+
+// Evaluate simulates the candidate for ticks and measures it.
+func (c *Candidate) Evaluate(g *Game, cells int, ticks int) error {
+	game, err := g.NewCandidateGame(c.preset, cells)
+	if err != nil {
+		return err
+	}
+
+	window := max(1, ticks/10)
+	positions := [3][]Vector2{}
+	record := func(k int) {
+		for _, cell := range game.cells {
+			positions[k] = append(positions[k], cell.position)
+		}
+	}
+
+	for t := 0; t < ticks; t++ {
+		switch t {
+		case ticks - 2*window:
+			record(0)
+		case ticks - window:
+			record(1)
+		}
+
+		game.Step()
+
+		if t >= ticks-window {
+			c.kinetic += game.KineticEnergy() / float64(max(1, len(game.cells))) / float64(window)
+		}
+	}
+	record(2)
+
+	c.clusters = len(game.FindClusters(game.clusters.linkDistance, game.clusters.minSize))
+	c.entropy = game.SpatialEntropy(entropyBins)
+
+	if len(positions[0]) == len(positions[2]) {
+		c.persistence = game.Persistence(positions[0], positions[1], positions[2])
+	}
+
+	c.score = c.Score()
+	c.thumbnail = game.Thumbnail(thumbnailSize)
+	return nil
+}
+
+// SpatialEntropy returns the Shannon entropy of the particle counts in bins x bins regions, divided by its largest
+// possible value.
+func (g *Game) SpatialEntropy(bins int) float64 {
+	counts := make([]int, bins*bins)
+	for _, c := range g.cells {
+		column := min(bins-1, max(0, int(c.position.x/g.worldWidth*float64(bins))))
+		row := min(bins-1, max(0, int(c.position.y/g.worldHeight*float64(bins))))
+		counts[row*bins+column]++
+	}
+
+	entropy := 0.0
+	for _, count := range counts {
+		if count > 0 {
+			p := float64(count) / float64(len(g.cells))
+			entropy -= p * math.Log(p)
+		}
+	}
+
+	return entropy / math.Log(float64(min(len(counts), max(2, len(g.cells)))))
+}
+
+// Persistence returns the mean cosine between each particle's displacement from a to b and from b to c.
+func (g *Game) Persistence(a, b, c []Vector2) float64 {
+	sum, count := 0.0, 0
+	for i := range a {
+		first := g.Displacement(a[i], b[i])
+		second := g.Displacement(b[i], c[i])
+
+		lengths := first.Magnitude() * second.Magnitude()
+		if lengths > 0 {
+			sum += (first.x*second.x + first.y*second.y) / lengths
+			count++
+		}
+	}
+
+	if count == 0 {
+		return 0
+	}
+	return sum / float64(count)
+}
+
+// Thumbnail draws the particles as small dots on black, width pixels wide.
+func (g *Game) Thumbnail(width int) *image.RGBA {
+	scale := float64(width) / g.worldWidth
+	height := max(1, int(g.worldHeight*scale))
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.Black, image.Point{}, draw.Src)
+
+	for _, c := range g.cells {
+		x, y := int(c.position.x*scale), int(c.position.y*scale)
+		for dy := 0; dy < 2; dy++ {
+			for dx := 0; dx < 2; dx++ {
+				img.Set(x+dx, y+dy, c.cellColor)
+			}
+		}
+	}
+
+	return img
+}
+
+// RunExplorer samples count random rule matrices on top of g's settings, simulates each headless for ticks and
+// writes every candidate's preset and thumbnail into dir, together with ranking.csv listing them by score.
+func (g *Game) RunExplorer(count, cells, ticks, workers int, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	template := g.Preset()
+	candidates := make([]*Candidate, count)
+	for k := range candidates {
+		// each candidate draws its rules from its own seed, which is also the seed it spawns with
+		p := template
+		p.Seed = g.seed + int64(k)
+		rng := rand.New(rand.NewSource(p.Seed))
+
+		p.Rules = make([][]float64, p.Species)
+		for i := range p.Rules {
+			p.Rules[i] = make([]float64, p.Species)
+			for j := range p.Rules[i] {
+				p.Rules[i][j] = rng.Float64()*2 - 1
+			}
+		}
+
+		candidates[k] = &Candidate{index: k, preset: p}
+	}
+
+	// workers claim candidates one at a time, each simulated on a single goroutine
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	errs := make([]error, count)
+
+	for w := 0; w < max(1, workers); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				k := int(next.Add(1)) - 1
+				if k >= count {
+					return
+				}
+
+				errs[k] = candidates[k].Evaluate(g, cells, ticks)
+				fmt.Printf("candidate %d: score %.3f\n", k, candidates[k].score)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	sort.SliceStable(candidates, func(a, b int) bool {
+		return candidates[a].score > candidates[b].score
+	})
+
+	return WriteCandidates(candidates, dir)
+}
+
+func WriteCandidates(candidates []*Candidate, dir string) error {
+	ranking, err := os.Create(filepath.Join(dir, "ranking.csv"))
+	if err != nil {
+		return err
+	}
+	defer ranking.Close()
+
+	w := csv.NewWriter(ranking)
+	w.Write([]string{"rank", "score", "kinetic", "clusters", "entropy", "persistence", "preset", "thumbnail", "share"})
+
+	for rank, c := range candidates {
+		name := fmt.Sprintf("candidate-%03d", c.index)
+		presetPath := filepath.Join(dir, name+".json")
+		thumbnailPath := filepath.Join(dir, name+".png")
+
+		if err := SavePreset(presetPath, c.preset); err != nil {
+			return err
+		}
+
+		if err := WritePNG(thumbnailPath, c.thumbnail); err != nil {
+			return err
+		}
+
+		share, err := EncodePreset(c.preset)
+		if err != nil {
+			return err
+		}
+
+		w.Write([]string{
+			strconv.Itoa(rank + 1),
+			strconv.FormatFloat(c.score, 'f', 4, 64),
+			strconv.FormatFloat(c.kinetic, 'f', 2, 64),
+			strconv.Itoa(c.clusters),
+			strconv.FormatFloat(c.entropy, 'f', 4, 64),
+			strconv.FormatFloat(c.persistence, 'f', 4, 64),
+			presetPath,
+			thumbnailPath,
+			share,
+		})
+
+		if rank < 10 {
+			fmt.Printf("%2d. %s score %.3f (kinetic %.1f, %d clusters, entropy %.3f, persistence %.3f)\n",
+				rank+1, presetPath, c.score, c.kinetic, c.clusters, c.entropy, c.persistence)
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+func WritePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/csv"
+	"image/png"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestSpatialEntropy(t *testing.T) {
+	g := newPresetGame(2, 0)
+	for i := 0; i < 500; i++ {
+		g.AddCell(CellConstructor(0, nil, Vector2{321, 123}, 2))
+	}
+	if entropy := g.SpatialEntropy(entropyBins); entropy != 0 {
+		t.Errorf("particles in one spot have entropy %g, want 0", entropy)
+	}
+
+	g = newPresetGame(2, 0)
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 50000; i++ {
+		g.AddCell(CellConstructor(0, nil, Vector2{rng.Float64() * g.worldWidth, rng.Float64() * g.worldHeight}, 2))
+	}
+	if entropy := g.SpatialEntropy(entropyBins); entropy < 0.99 || entropy > 1 {
+		t.Errorf("uniformly spread particles have entropy %g, want about 1", entropy)
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	g := newPresetGame(2, 0)
+	a := []Vector2{{100, 100}, {500, 300}}
+	b := []Vector2{{110, 105}, {500, 280}}
+	c := []Vector2{{130, 115}, {500, 270}}
+	if p := g.Persistence(a, b, c); math.Abs(p-1) > 1e-12 {
+		t.Errorf("straight-line motion has persistence %g, want 1", p)
+	}
+
+	if p := g.Persistence(a, b, a); math.Abs(p+1) > 1e-12 {
+		t.Errorf("turning back has persistence %g, want -1", p)
+	}
+}
+
+func TestRunExplorer(t *testing.T) {
+	g := newPresetGame(3, 0)
+	g.clusters = ClusterTracker{linkDistance: 10, minSize: 3}
+	dir := t.TempDir()
+
+	if err := g.RunExplorer(2, 200, 5, 2, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(filepath.Join(dir, "ranking.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[0][0] != "rank" || rows[0][6] != "preset" || rows[0][7] != "thumbnail" {
+		t.Fatalf("ranking has rows %v, want a header and 2 candidates", rows)
+	}
+
+	previous := math.Inf(1)
+	for k, row := range rows[1:] {
+		if row[0] != strconv.Itoa(k+1) {
+			t.Errorf("row %d has rank %s", k+1, row[0])
+		}
+
+		score, err := strconv.ParseFloat(row[1], 64)
+		if err != nil || score < 0 || score > 1 || score > previous {
+			t.Errorf("rank %d has score %s after %g", k+1, row[1], previous)
+		}
+		previous = score
+
+		p, err := LoadPreset(row[6])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := newPresetGame(3, 0).ApplyPreset(p); err != nil {
+			t.Errorf("%s: %v", row[6], err)
+		}
+
+		shared, err := DecodePreset(row[8])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if shared.Seed != p.Seed || shared.Rules[2][1] != p.Rules[2][1] {
+			t.Errorf("rank %d shares a different preset than %s", k+1, row[6])
+		}
+
+		thumbnail, err := os.Open(row[7])
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(thumbnail)
+		thumbnail.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", row[7], err)
+		}
+		if img.Bounds().Dx() != thumbnailSize {
+			t.Errorf("%s is %d pixels wide, want %d", row[7], img.Bounds().Dx(), thumbnailSize)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ForceLaw gives the force between two particles at distance d, positive pulling them together. Every law is cut
+// off at the pair's radius so the spatial grid finds all interactions, and reads the pair's rule and beta in its
+// own way.
+type ForceLaw interface {
+	Force(d, radius, rule, beta float64) float64
+	Name() string
+}
+
+var forceLaws = map[string]ForceLaw{
+	PiecewiseLinear{}.Name(): PiecewiseLinear{},
+	InverseSquare{}.Name():   InverseSquare{},
+	LennardJones{}.Name():    LennardJones{},
+	Gaussian{}.Name():        Gaussian{},
+}
+
+// ForceLawNames returns the names of every law in alphabetical order.
+func ForceLawNames() []string {
+	names := []string{}
+	for name := range forceLaws {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func ParseForceLaw(name string) (ForceLaw, error) {
+	law, ok := forceLaws[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown force law %q, expected one of %v", name, ForceLawNames())
+	}
+	return law, nil
+}
+
+// NextForceLaw returns the law after law in alphabetical order, wrapping around.
+func NextForceLaw(law ForceLaw) ForceLaw {
+	names := ForceLawNames()
+	for i, name := range names {
+		if name == law.Name() {
+			return forceLaws[names[(i+1)%len(names)]]
+		}
+	}
+	return forceLaws[names[0]]
+}
+
+// PiecewiseLinear repels linearly inside beta*radius and attracts or repels by rule with a triangle peaking halfway
+// between beta*radius and the radius.
+type PiecewiseLinear struct{}
+
+func (PiecewiseLinear) Name() string { return "linear" }
+
+func (PiecewiseLinear) Force(d, radius, rule, beta float64) float64 {
+	distance := d / radius
+	if distance < beta {
+		return (distance/beta - 1) * radius
+	} else if beta < distance && distance < 1 {
+		return rule * (1 - math.Abs(2*distance-1-beta)/(1-beta)) * radius
+	} else {
+		return 0
+	}
+}
+
+// InverseSquare is gravity-like attraction scaled by rule, softened over beta*radius so that it stays finite when
+// particles overlap.
+type InverseSquare struct{}
+
+func (InverseSquare) Name() string { return "inverse-square" }
+
+func (InverseSquare) Force(d, radius, rule, beta float64) float64 {
+	if d >= radius {
+		return 0
+	}
+
+	// scaled so that the force peaks at rule*radius, like the linear law
+	softening := beta * radius
+	peak := 2 / (3 * math.Sqrt(3)) / (softening * softening)
+	return rule * radius / peak * d / math.Pow(d*d+softening*softening, 1.5)
+}
+
+// LennardJones has the potential minimum at beta*radius. Rule scales the attractive r^-6 term, so rules at or below
+// zero leave only repulsion. The force is clamped to the radius, the largest force of the linear law, so that
+// overlapping particles do not fly apart.
+type LennardJones struct{}
+
+func (LennardJones) Name() string { return "lennard-jones" }
+
+func (LennardJones) Force(d, radius, rule, beta float64) float64 {
+	if d >= radius {
+		return 0
+	}
+
+	sigma := beta * radius / math.Pow(2, 1.0/6)
+
+	// a depth for which the largest attraction at rule 1 is about rule*radius
+	epsilon := sigma * radius / 2.4
+
+	s6 := math.Pow(sigma/d, 6)
+	force := 24 * epsilon / d * (rule*s6 - 2*s6*s6)
+	return math.Max(-radius, math.Min(radius, force))
+}
+
+// Gaussian comes from a Gaussian well of width beta*radius, attracting by rule with the largest force rule*radius at
+// the width and fading to nothing as particles overlap.
+type Gaussian struct{}
+
+func (Gaussian) Name() string { return "gaussian" }
+
+func (Gaussian) Force(d, radius, rule, beta float64) float64 {
+	if d >= radius {
+		return 0
+	}
+
+	x := d / (beta * radius)
+	return rule * radius * x * math.Exp(0.5-x*x/2)
+}
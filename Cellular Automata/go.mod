@@ -1,4 +1,4 @@
-module main
+module cellularautomata
 
 go 1.21.3
 
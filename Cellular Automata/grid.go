@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// SpatialGrid buckets particle indices by partition. It is rebuilt from scratch every tick with a counting sort, so
+// the particles of partition p are indices[cellStart[p]:cellStart[p+1]].
+type SpatialGrid struct {
+	radius     float64
+	columns    int
+	rows       int
+	cellWidth  float64
+	cellHeight float64
+
+	cellStart   []int
+	indices     []int
+	partitionOf []int
+}
+
+// NewSpatialGrid sizes the partitions so that each one is at least radius wide and high.
+func NewSpatialGrid(width, height, radius float64) *SpatialGrid {
+	s := &SpatialGrid{
+		radius:  radius,
+		columns: max(1, int(width/radius)),
+		rows:    max(1, int(height/radius)),
+	}
+	s.cellWidth = width / float64(s.columns)
+	s.cellHeight = height / float64(s.rows)
+	s.cellStart = make([]int, s.columns*s.rows+1)
+	return s
+}
+
+// Index returns the column and row of the partition containing position, clamped to the grid.
+func (s *SpatialGrid) Index(position Vector2) (int, int) {
+	clamp := func(value float64, size float64, count int) int {
+		// also catches NaN
+		if !(value >= 0) {
+			return 0
+		}
+
+		// clamped before converting, since converting infinity or a huge value to int overflows
+		return int(math.Min(value/size, float64(count-1)))
+	}
+
+	return clamp(position.x, s.cellWidth, s.columns), clamp(position.y, s.cellHeight, s.rows)
+}
+
+// Rebuild sorts every particle into its partition. Particles keep their relative order within a partition.
+func (s *SpatialGrid) Rebuild(cells []*Cell) {
+	if cap(s.indices) < len(cells) {
+		s.indices = make([]int, len(cells))
+		s.partitionOf = make([]int, len(cells))
+	}
+	s.indices = s.indices[:len(cells)]
+	s.partitionOf = s.partitionOf[:len(cells)]
+
+	for p := range s.cellStart {
+		s.cellStart[p] = 0
+	}
+
+	// count, shifted by one so the prefix sum leaves the start offsets in place
+	for i, c := range cells {
+		column, row := s.Index(c.position)
+		s.partitionOf[i] = row*s.columns + column
+		s.cellStart[s.partitionOf[i]+1]++
+	}
+
+	for p := 1; p < len(s.cellStart); p++ {
+		s.cellStart[p] += s.cellStart[p-1]
+	}
+
+	// cellStart[p] is used as the insertion cursor and ends up at the start of partition p+1, so shift it back
+	for i := range cells {
+		p := s.partitionOf[i]
+		s.indices[s.cellStart[p]] = i
+		s.cellStart[p]++
+	}
+
+	for p := len(s.cellStart) - 1; p > 0; p-- {
+		s.cellStart[p] = s.cellStart[p-1]
+	}
+	s.cellStart[0] = 0
+}
+
+// Partition returns the indices of the particles in the given partition.
+func (s *SpatialGrid) Partition(column, row int) []int {
+	p := row*s.columns + column
+	return s.indices[s.cellStart[p]:s.cellStart[p+1]]
+}
+
+// Check verifies that every particle is stored exactly once, in the partition its position maps to.
+func (s *SpatialGrid) Check(cells []*Cell) error {
+	if len(s.indices) != len(cells) {
+		return fmt.Errorf("grid holds %d particles, expected %d", len(s.indices), len(cells))
+	}
+
+	if s.cellStart[0] != 0 || s.cellStart[len(s.cellStart)-1] != len(cells) {
+		return fmt.Errorf("partition offsets span [%d, %d), expected [0, %d)", s.cellStart[0], s.cellStart[len(s.cellStart)-1], len(cells))
+	}
+
+	seen := make([]bool, len(cells))
+	for row := 0; row < s.rows; row++ {
+		for column := 0; column < s.columns; column++ {
+			p := row*s.columns + column
+			if s.cellStart[p] > s.cellStart[p+1] {
+				return fmt.Errorf("partition %d has negative size", p)
+			}
+
+			for _, i := range s.Partition(column, row) {
+				if i < 0 || i >= len(cells) {
+					return fmt.Errorf("partition %d holds out of range particle %d", p, i)
+				}
+				if seen[i] {
+					return fmt.Errorf("particle %d is stored twice", i)
+				}
+				seen[i] = true
+
+				if c, r := s.Index(cells[i].position); c != column || r != row {
+					return fmt.Errorf("particle %d is in partition (%d, %d) but its position maps to (%d, %d)", i, column, row, c, r)
+				}
+			}
+		}
+	}
+
+	return nil
+}
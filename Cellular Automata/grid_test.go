@@ -0,0 +1,162 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func gridCells(positions ...Vector2) []*Cell {
+	cells := []*Cell{}
+	for _, p := range positions {
+		c := CellConstructor(GreenCell, nil, p, 2)
+		cells = append(cells, &c)
+	}
+	return cells
+}
+
+func rebuiltGrid(t *testing.T, cells []*Cell) *SpatialGrid {
+	t.Helper()
+
+	s := NewSpatialGrid(1000, 700, 100)
+	s.Rebuild(cells)
+	if err := s.Check(cells); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestGridRandomPositions(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	positions := []Vector2{}
+	for i := 0; i < 5000; i++ {
+		positions = append(positions, Vector2{rng.Float64() * 1000, rng.Float64() * 700})
+	}
+
+	s := rebuiltGrid(t, gridCells(positions...))
+	if s.columns != 10 || s.rows != 7 {
+		t.Errorf("grid is %dx%d, want 10x7", s.columns, s.rows)
+	}
+}
+
+func TestGridEdges(t *testing.T) {
+	cells := gridCells(
+		Vector2{0, 0},
+		Vector2{1000, 700},
+		Vector2{100, 100},
+		Vector2{99.99999, 100},
+		Vector2{1000, 0},
+		Vector2{0, 700},
+	)
+	s := rebuiltGrid(t, cells)
+
+	want := [][2]int{{0, 0}, {9, 6}, {1, 1}, {0, 1}, {9, 0}, {0, 6}}
+	for i, w := range want {
+		if column, row := s.Index(cells[i].position); column != w[0] || row != w[1] {
+			t.Errorf("%v maps to (%d, %d), want (%d, %d)", cells[i].position, column, row, w[0], w[1])
+		}
+	}
+}
+
+func TestGridOutsideAndNaN(t *testing.T) {
+	cells := gridCells(
+		Vector2{-50, 300},
+		Vector2{5000, -1},
+		Vector2{math.Inf(1), math.Inf(-1)},
+		Vector2{math.NaN(), 300},
+		Vector2{300, math.NaN()},
+	)
+	s := rebuiltGrid(t, cells)
+
+	want := [][2]int{{0, 3}, {9, 0}, {9, 0}, {0, 3}, {3, 0}}
+	for i, w := range want {
+		if column, row := s.Index(cells[i].position); column != w[0] || row != w[1] {
+			t.Errorf("%v maps to (%d, %d), want (%d, %d)", cells[i].position, column, row, w[0], w[1])
+		}
+	}
+}
+
+func TestGridEmpty(t *testing.T) {
+	s := rebuiltGrid(t, nil)
+	for row := 0; row < s.rows; row++ {
+		for column := 0; column < s.columns; column++ {
+			if n := len(s.Partition(column, row)); n != 0 {
+				t.Fatalf("empty grid has %d particles in (%d, %d)", n, column, row)
+			}
+		}
+	}
+}
+
+func TestGridRebuildAfterAddAndRemove(t *testing.T) {
+	g := &Game{worldWidth: 1000, worldHeight: 700, attractionDistance: 100}
+	g.SetSpeciesCount(3)
+	g.SpawnCells(500, rand.New(rand.NewSource(2)))
+
+	g.RebuildGrid()
+	if err := g.grid.Check(g.cells); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 50; i++ {
+		g.AddCell(CellConstructor(BlueCell, nil, Vector2{float64(i) * 20, 650}, 2))
+	}
+	g.RebuildGrid()
+	if err := g.grid.Check(g.cells); err != nil {
+		t.Fatal(err)
+	}
+
+	g.RemoveCells(func(c *Cell) bool { return c.position.x < 400 })
+	g.RebuildGrid()
+	if err := g.grid.Check(g.cells); err != nil {
+		t.Fatal(err)
+	}
+
+	// a stale grid no longer matches
+	g.AddCell(CellConstructor(BlueCell, nil, Vector2{10, 10}, 2))
+	if err := g.grid.Check(g.cells); err == nil {
+		t.Error("grid built before adding a particle passed the check")
+	}
+}
+
+func TestGridCheckDetectsCorruption(t *testing.T) {
+	cells := gridCells(Vector2{50, 50}, Vector2{550, 450}, Vector2{950, 650})
+	s := rebuiltGrid(t, cells)
+
+	// moving a particle without rebuilding leaves it in the wrong partition
+	cells[0].position = Vector2{650, 50}
+	if err := s.Check(cells); err == nil {
+		t.Error("moved particle passed the check")
+	}
+	cells[0].position = Vector2{50, 50}
+
+	s.indices[0] = s.indices[1]
+	if err := s.Check(cells); err == nil {
+		t.Error("duplicated particle passed the check")
+	}
+}
+
+func TestNeighborIndices(t *testing.T) {
+	tests := []struct {
+		boundary     BoundaryMode
+		index, count int
+		want         []int
+	}{
+		{BoundaryWalls, 4, 10, []int{3, 4, 5}},
+		{BoundaryWalls, 0, 10, []int{0, 1}},
+		{BoundaryWalls, 9, 10, []int{8, 9}},
+		{BoundaryWalls, 0, 1, []int{0}},
+		{BoundaryWrap, 0, 10, []int{9, 0, 1}},
+		{BoundaryWrap, 9, 10, []int{8, 9, 0}},
+		{BoundaryWrap, 0, 2, []int{1, 0}},
+		{BoundaryWrap, 0, 1, []int{0}},
+	}
+
+	for _, test := range tests {
+		g := &Game{boundary: test.boundary}
+		neighbors, n := g.NeighborIndices(test.index, test.count)
+		if !slices.Equal(neighbors[:n], test.want) {
+			t.Errorf("%s: neighbors of %d in %d are %v, want %v", test.boundary, test.index, test.count, neighbors[:n], test.want)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+var digitKeys = []ebiten.Key{
+	ebiten.KeyDigit0, ebiten.KeyDigit1, ebiten.KeyDigit2, ebiten.KeyDigit3, ebiten.KeyDigit4,
+	ebiten.KeyDigit5, ebiten.KeyDigit6, ebiten.KeyDigit7, ebiten.KeyDigit8, ebiten.KeyDigit9,
+}
+
+// Frame is the particle state at the start of a tick.
+type Frame struct {
+	tick  int
+	cells []Cell
+}
+
+// History is a ring buffer of the most recent frames. Only particles are recorded, so stepping back keeps the current
+// rules, and stepping forward again after editing them shows what the edit changes.
+type History struct {
+	frames []Frame
+	start  int // index of the oldest frame
+	count  int
+}
+
+func NewHistory(capacity int) History {
+	return History{frames: make([]Frame, max(0, capacity))}
+}
+
+// Push records the cells, overwriting the oldest frame once the buffer is full. A zero capacity history records
+// nothing.
+func (h *History) Push(tick int, cells []*Cell) {
+	if len(h.frames) == 0 {
+		return
+	}
+
+	var frame *Frame
+	if h.count < len(h.frames) {
+		frame = &h.frames[(h.start+h.count)%len(h.frames)]
+		h.count++
+	} else {
+		frame = &h.frames[h.start]
+		h.start = (h.start + 1) % len(h.frames)
+	}
+
+	// reuse the slice of the frame being overwritten
+	frame.tick = tick
+	frame.cells = frame.cells[:0]
+	for _, c := range cells {
+		frame.cells = append(frame.cells, *c)
+	}
+}
+
+// Pop removes and returns the newest frame. Its cells are only valid until the next Push.
+func (h *History) Pop() (Frame, bool) {
+	if h.count == 0 {
+		return Frame{}, false
+	}
+
+	h.count--
+	return h.frames[(h.start+h.count)%len(h.frames)], true
+}
+
+func (h *History) Len() int {
+	return h.count
+}
+
+func (h *History) Clear() {
+	h.start = 0
+	h.count = 0
+}
+
+// Restore replaces the particles with the frame's.
+func (g *Game) Restore(frame Frame) {
+	g.cells = g.cells[:0]
+	for _, c := range frame.cells {
+		c := c
+		g.cells = append(g.cells, &c)
+	}
+	g.tick = frame.tick
+	g.CountSpecies()
+}
+
+// StepBack restores the previous tick from the history, returning false when there is none.
+func (g *Game) StepBack() bool {
+	frame, ok := g.timeline.history.Pop()
+	if ok {
+		g.Restore(frame)
+	}
+	return ok
+}
+
+// Timeline holds the history together with the step mode controls: left steps back (shift for stepCount ticks),
+// right steps forward stepCount ticks, T followed by digits and enter plays forward or backward until that tick, X
+// exports the current frame and shift+X restores the last exported one.
+type Timeline struct {
+	history   History
+	stepCount int
+
+	// tick to play until, -1 when not playing
+	target int
+
+	entering bool
+	entry    string
+
+	snapshotDir  string
+	lastSnapshot string
+}
+
+func (t *Timeline) Playing() bool {
+	return t.target >= 0
+}
+
+func (t *Timeline) Update(g *Game) {
+	if t.entering {
+		t.UpdateEntry()
+		return
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyT) {
+		t.entering = true
+		t.entry = ""
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyArrowLeft) {
+		count := 1
+		if ebiten.IsKeyPressed(ebiten.KeyShift) {
+			count = t.stepCount
+		}
+		for i := 0; i < count; i++ {
+			if !g.StepBack() {
+				break
+			}
+		}
+		g.requiresInput = true
+		t.target = -1
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyArrowRight) {
+		for i := 0; i < t.stepCount; i++ {
+			g.Step()
+		}
+		g.requiresInput = true
+		t.target = -1
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyX) && !ebiten.IsKeyPressed(ebiten.KeyShift) {
+		if err := g.ExportSnapshot(t.snapshotDir); err != nil {
+			log.Println(err)
+		}
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyX) && ebiten.IsKeyPressed(ebiten.KeyShift) && t.lastSnapshot != "" {
+		if err := g.RestoreSnapshotFile(t.lastSnapshot); err != nil {
+			log.Println(err)
+		}
+		t.target = -1
+	}
+
+	// play one tick per frame so the way there can be watched, then pause
+	if t.Playing() {
+		switch {
+		case g.tick < t.target:
+			g.Step()
+		case g.tick > t.target && g.StepBack():
+		default:
+			if g.tick != t.target {
+				log.Printf("history only reaches back to tick %d", g.tick)
+			}
+			t.target = -1
+			g.requiresInput = true
+		}
+	}
+}
+
+// UpdateEntry reads the digits of the tick to play until.
+func (t *Timeline) UpdateEntry() {
+	for digit, key := range digitKeys {
+		if inpututil.IsKeyJustPressed(key) {
+			t.entry += strconv.Itoa(digit)
+		}
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyBackspace) && len(t.entry) > 0 {
+		t.entry = t.entry[:len(t.entry)-1]
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+		t.entering = false
+		if target, err := strconv.Atoi(t.entry); err == nil {
+			t.target = target
+		}
+	}
+}
+
+// Status returns the timeline lines of the debug text.
+func (t *Timeline) Status(g *Game) string {
+	status := fmt.Sprintf("Tick: %d\nHistory: %d", g.tick, t.history.Len())
+	if t.entering {
+		status += fmt.Sprintf("\nPlay until: %s_", t.entry)
+	} else if t.Playing() {
+		status += fmt.Sprintf("\nPlaying until: %d", t.target)
+	}
+	return status
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// energyCheckGame returns a single species world large enough that no particle reaches an edge.
+func energyCheckGame(integrator Integrator, substeps int, halfLife float64) *Game {
+	g := &Game{
+		worldWidth:         100 * defaultAttractionDistance,
+		worldHeight:        100 * defaultAttractionDistance,
+		attractionDistance: defaultAttractionDistance,
+		halfLife:           halfLife,
+		substeps:           substeps,
+		integrator:         integrator,
+		workers:            1,
+	}
+	g.SetSpeciesCount(1)
+	return g
+}
+
+// addCheckCell adds a species 0 particle of unit mass and charge with the given position and velocity.
+func addCheckCell(g *Game, x, y, vx, vy float64) *Cell {
+	c := g.AddCell(CellConstructor(0, g.colors[0], Vector2{x, y}, 2))
+	c.velocity = Vector2{vx, vy}
+	return c
+}
+
+// energyDrift runs a frictionless two-particle setup for 500 ticks and returns the largest relative energy drift and
+// the largest total momentum seen.
+func energyDrift(integrator string, substeps int, law ForceLaw, rule, distance, mass, charge float64) (float64, float64) {
+	g := energyCheckGame(integrators[integrator], substeps, 0)
+	g.rules[0][0] = rule
+	g.laws[0][0] = law
+	a := addCheckCell(g, g.worldWidth/2, g.worldHeight/2, 0, 0)
+	b := addCheckCell(g, g.worldWidth/2+distance*g.attractionDistance, g.worldHeight/2, 0, 0)
+	b.mass = mass
+	a.charge, b.charge = charge, charge
+
+	start := g.Energy()
+	scale := math.Max(math.Abs(start), 1e-9)
+	drift, momentum := 0.0, 0.0
+	for t := 0; t < 500; t++ {
+		g.Step()
+		drift = math.Max(drift, math.Abs(g.Energy()-start)/scale)
+		momentum = math.Max(momentum, g.Momentum())
+	}
+	return drift, momentum
+}
+
+func TestIntegratorEnergy(t *testing.T) {
+	setups := []struct {
+		name     string
+		law      ForceLaw
+		rule     float64
+		distance float64 // starting distance as a fraction of the radius
+		mass     float64 // of the second particle, the first has mass 1
+		charge   float64 // of both particles
+	}{
+		{"attract", PiecewiseLinear{}, 1, 0.5, 1, 1}, // close enough that the core stops the particles before they meet
+		{"repel", PiecewiseLinear{}, -1, 0.5, 1, 1},
+		{"core", PiecewiseLinear{}, 0, 0.1, 1, 1},
+		{"gravity", InverseSquare{}, 1, 0.5, 1, 1},
+		{"lj", LennardJones{}, 1, 0.4, 1, 1},
+		{"gauss", Gaussian{}, 1, 0.5, 1, 1},
+		{"heavy", PiecewiseLinear{}, 1, 0.5, 5, 1},
+		{"charged", Gaussian{}, 1, 0.5, 3, 0.5},
+	}
+
+	for _, setup := range setups {
+		setup := setup
+		t.Run(setup.name, func(t *testing.T) {
+			t.Parallel()
+
+			for _, substeps := range []int{1, 4} {
+				drifts := map[string]float64{}
+				for _, name := range []string{"euler", "verlet", "rk4"} {
+					drift, momentum := energyDrift(name, substeps, setup.law, setup.rule, setup.distance, setup.mass, setup.charge)
+					drifts[name] = drift
+
+					if momentum > 1e-9 {
+						t.Errorf("%s x%d: momentum reached %.3e", name, substeps, momentum)
+					}
+				}
+
+				// the symplectic and higher order schemes stay close at the substeps the presets use for stiff laws
+				if substeps == 4 {
+					for _, name := range []string{"verlet", "rk4"} {
+						if drifts[name] > 1e-2 {
+							t.Errorf("%s x%d: energy drifted by %.3e", name, substeps, drifts[name])
+						}
+					}
+				}
+
+				if drifts["euler"] <= drifts["verlet"] || drifts["euler"] <= drifts["rk4"] {
+					t.Errorf("x%d: euler drifted by %.3e, no more than verlet %.3e or rk4 %.3e", substeps, drifts["euler"], drifts["verlet"], drifts["rk4"])
+				}
+			}
+		})
+	}
+}
+
+func TestFrictionHalfLife(t *testing.T) {
+	halfLife := 10 * deltaT
+	for name, integrator := range integrators {
+		for _, substeps := range []int{1, 4} {
+			g := energyCheckGame(integrator, substeps, halfLife)
+			addCheckCell(g, g.worldWidth/2, g.worldHeight/2, 100, 0)
+
+			for tick := 0; tick < 10; tick++ {
+				g.Step()
+			}
+
+			if kept := g.cells[0].velocity.Magnitude() / 100; math.Abs(kept-0.5) > 1e-12 {
+				t.Errorf("%s x%d: kept %.15f of the speed after one half-life, want 0.5", name, substeps, kept)
+			}
+		}
+	}
+}
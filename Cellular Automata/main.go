@@ -1,12 +1,14 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"image/color"
 	"log"
 	"math"
 	"math/rand"
-	"slices"
+	"runtime"
+	"strings"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
@@ -17,36 +19,127 @@ const (
 	screenWidth  = 1000
 	screenHeight = 1000
 
-	deltaT             = 0.02
-	attractionDistance = 100
-	friction           = 0.7
+	deltaT                    = 0.02 // Simulated time per Step
+	defaultAttractionDistance = 100
+
+	// Matches the old friction of 0.7 per tick at deltaT: deltaT * ln(0.5) / ln(0.7)
+	defaultHalfLife = 0.0389
 
 	cellSize = 5
 
 	gravityStrength = 1
+
+	defaultBeta = 0.3
+	maxRadius   = 300
+
+	maxSpecies = 32
 )
 
 type Game struct {
-	cells          []*Cell
-	rules          [10][10]float64
-	requiresInput  bool
-	partitionBoard [10][10][]*Cell
+	cells         []*Cell
+	rules         [][]float64
+	colors        []color.Color
+	configColors  []color.Color
+	mix           []float64
+	requiresInput bool
+	grid          *SpatialGrid
+
+	worldWidth         float64
+	worldHeight        float64
+	attractionDistance float64
+
+	// simulated time for a velocity to halve without forces, 0 disables friction
+	halfLife   float64
+	substeps   int
+	integrator Integrator
+
+	// scratch buffers of the integrators, one entry per cell
+	accelerations []Vector2
+	stages        [][]Vector2
+
+	// per species pair interaction radius and core repulsion fraction, indexed like rules
+	radii [][]float64
+	betas [][]float64
+
+	// per species pair force law, and the law of pairs added when the species count grows
+	laws       [][]ForceLaw
+	defaultLaw ForceLaw
+
+	// ID of the last particle added, see AddCell
+	nextID uint64
+
+	// per species spawn distributions of mass and charge, 1 for species without one
+	masses  []Distribution
+	charges []Distribution
+
+	// draw particles with an area proportional to their mass
+	massRadius bool
+
+	// force without cutoff added between every pair of particles, nil for none, summed with a Barnes–Hut tree of
+	// opening angle theta unless longRangeDirect, see LongRangeLaw
+	longRange         LongRangeLaw
+	longRangeStrength float64
+	softening         float64
+	theta             float64
+	longRangeDirect   bool
+	tree              QuadTree
+
+	// reactions run after every tick, and the particle count of every species they keep up to date
+	reactions     []Reaction
+	speciesCounts []int
+
+	// seed of rng, which every random choice is drawn from, see Seed
+	seed      int64
+	rng       *rand.Rand
+	rngSource *CountingSource
+
+	presetDir   string
+	presetIndex int
+
+	boundary BoundaryMode
+	editor   RuleEditor
+	brush    Brush
+	clusters ClusterTracker
+	renderer Renderer
+	charts   Charts
+
+	// ticks simulated since the particles were spawned
+	tick     int
+	timeline Timeline
+
+	// validate the grid every tick, see SpatialGrid.Check
+	checkGrid bool
+
+	// goroutines used for the force phase
+	workers int
 }
 
-func (g *Game) CalculateForce(distance float64, attractionForce float64) float64 {
-	beta := 0.3
-	if distance < beta {
-		return distance/beta - 1
-	} else if beta < distance && distance < 1 {
-		return attractionForce * (1 - math.Abs(2*distance-1-beta)/(1-beta))
-	} else {
-		return 0
+// Force returns the force magnitude between species a and b at distance d under the pair's law.
+func (g *Game) Force(a, b CellType, d float64) float64 {
+	return g.laws[a][b].Force(d, g.radii[a][b], g.rules[a][b], g.betas[a][b])
+}
+
+// PairForce returns the force b exerts on a, scaled by b's charge. A particle exerts none on itself.
+func (g *Game) PairForce(a, b *Cell) Vector2 {
+	if a.id == b.id {
+		return Vector2{0, 0}
 	}
+
+	direction := g.Displacement(a.position, b.position)
+	distance := direction.Magnitude()
+
+	if distance <= 0.0001 || distance >= g.radii[a.cellType][b.cellType] {
+		return Vector2{0, 0}
+	}
+
+	force := g.Force(a.cellType, b.cellType, distance) * b.charge
+	direction.Normalize()
+	return Vector2{direction.x * force, direction.y * force}
 }
 
 func (g *Game) PrintRules() {
-	for row := 0; row < 4; row++ {
-		for column := 0; column < 4; column++ {
+	for row := range g.rules {
+		for column := range g.rules[row] {
 			fmt.Print(g.rules[row][column], " ")
 		}
 		fmt.Print("\n")
@@ -55,363 +148,367 @@ func (g *Game) PrintRules() {
 }
 
 func (g *Game) RandomizeRules() {
-	for i := 0; i < 4; i++ {
-		for j := 0; j < 4; j++ {
-			g.rules[i][j] = rand.Float64()*2 - 1
+	for i := range g.rules {
+		for j := range g.rules[i] {
+			g.rules[i][j] = g.Rand().Float64()*2 - 1
 		}
 	}
 	g.PrintRules()
 }
 
-func (g *Game) CalculatePartialForce(a *Cell, cells []*Cell) Vector2 {
+func (g *Game) CalculatePartialForce(i int, neighbors []int) Vector2 {
 	partialForce := Vector2{0, 0}
-	for j := 0; j < len(cells); j++ {
-		if a == cells[j] {
-			continue
-		}
-
-		direction := Vector2{cells[j].position.x - a.position.x, cells[j].position.y - a.position.y}
-		distance := direction.Magnitude()
-
-		if distance <= 0.0001 {
-			//fmt.Printf("PARTICLES TOO CLOSE: %p AND %p\n", a, cells[j])
-			continue
-		}
-
-		if distance < attractionDistance {
-			force := g.CalculateForce(distance/attractionDistance, g.rules[a.cellType][cells[j].cellType])
-			direction.Normalize()
-			partialForce.x += direction.x * force
-			partialForce.y += direction.y * force
-		}
+	for _, j := range neighbors {
+		force := g.PairForce(g.cells[i], g.cells[j])
+		partialForce.x += force.x
+		partialForce.y += force.y
 	}
 	return partialForce
 }
 
-func ConvertToPartitionBoardIndex(position float64) uint {
-	index := uint(position) / attractionDistance
-
-	if index == screenHeight/attractionDistance {
-		index -= 1
-	}
-
-	return index
-}
-
 func (g *Game) Update() error {
-	if (g.requiresInput && inpututil.IsKeyJustPressed(ebiten.KeySpace)) || !g.requiresInput {
-		/*
-			for i := 0; i < len(g.cells); i++ {
-				totalForce := Vector2{0, 0}
-				a := g.cells[i]
-				for j := 0; j < len(g.cells); j++ {
-					if i == j {
-						continue
-					}
-
-					b := g.cells[j]
-
-					direction := Vector2{b.position.x - a.position.x, b.position.y - a.position.y}
-					distance := direction.Magnitude()
-
-					if distance < attractionDistance {
-						force := g.CalculateForce(distance/attractionDistance, g.rules[a.cellType][b.cellType])
-						direction.Normalize()
-						totalForce.x += direction.x * force
-						totalForce.y += direction.y * force
-					}
-				}
-
-				totalForce.x *= attractionDistance
-				totalForce.y *= attractionDistance
-
-				g.cells[i].velocity.x *= friction
-				g.cells[i].velocity.y *= friction
-
-				g.cells[i].velocity.x += totalForce.x * deltaT
-				g.cells[i].velocity.y += totalForce.y * deltaT
-			}
-		*/
-
-		//fmt.Println("NEW UPDATE")
-
-		for i := 0; i < len(g.cells); i++ {
-			totalForce := Vector2{0, 0}
+	g.editor.Update(g)
+	g.brush.Update(g)
+	g.timeline.Update(g)
 
-			currentXIndex := ConvertToPartitionBoardIndex(g.cells[i].position.x)
-			currentYIndex := ConvertToPartitionBoardIndex(g.cells[i].position.y)
+	if (g.requiresInput && inpututil.IsKeyJustPressed(ebiten.KeySpace)) || (!g.requiresInput && !g.timeline.Playing()) {
+		g.Step()
+	}
 
-			// top left
-			if (currentXIndex >= 1) && (currentYIndex >= 1) {
-				partialForce := g.CalculatePartialForce(g.cells[i], g.partitionBoard[currentYIndex-1][currentXIndex-1])
+	if inpututil.IsKeyJustPressed(ebiten.KeyA) {
+		g.requiresInput = !g.requiresInput
+	}
 
-				totalForce.x += partialForce.x
-				totalForce.y += partialForce.y
-			}
+	if inpututil.IsKeyJustPressed(ebiten.KeyR) && !ebiten.IsKeyPressed(ebiten.KeyShift) {
+		g.RandomizeRules()
+	}
 
-			// top middle
-			if currentYIndex >= 1 {
-				partialForce := g.CalculatePartialForce(g.cells[i], g.partitionBoard[currentYIndex-1][currentXIndex])
+	if inpututil.IsKeyJustPressed(ebiten.KeyS) {
+		if err := g.SaveCurrentPreset(); err != nil {
+			log.Println(err)
+		}
+	}
 
-				totalForce.x += partialForce.x
-				totalForce.y += partialForce.y
-			}
+	if inpututil.IsKeyJustPressed(ebiten.KeyP) {
+		if err := g.LoadNextPreset(); err != nil {
+			log.Println(err)
+		}
+	}
 
-			// top right
-			if currentXIndex < (screenWidth/attractionDistance-1) && currentYIndex >= 1 {
-				partialForce := g.CalculatePartialForce(g.cells[i], g.partitionBoard[currentYIndex-1][currentXIndex+1])
+	if inpututil.IsKeyJustPressed(ebiten.KeyB) {
+		g.boundary = (g.boundary + 1) % (BoundaryWalls + 1)
+	}
 
-				totalForce.x += partialForce.x
-				totalForce.y += partialForce.y
-			}
+	if inpututil.IsKeyJustPressed(ebiten.KeyEqual) {
+		g.SetSpeciesCount(len(g.rules) + 1)
+		g.PrintRules()
+	}
 
-			// middle left
-			if currentXIndex >= 1 {
-				partialForce := g.CalculatePartialForce(g.cells[i], g.partitionBoard[currentYIndex][currentXIndex-1])
+	if inpututil.IsKeyJustPressed(ebiten.KeyMinus) {
+		g.SetSpeciesCount(len(g.rules) - 1)
+		g.PrintRules()
+	}
 
-				totalForce.x += partialForce.x
-				totalForce.y += partialForce.y
-			}
+	g.clusters.Update(g)
+	g.renderer.Update()
+	g.charts.Update()
 
-			// middle middle
-			if true {
-				partialForce := g.CalculatePartialForce(g.cells[i], g.partitionBoard[currentYIndex][currentXIndex])
+	return nil
+}
 
-				totalForce.x += partialForce.x
-				totalForce.y += partialForce.y
-			}
+// Step advances the simulation by one tick of deltaT simulated time, split into g.substeps integrator steps. Cells
+// may be moved, added or removed freely between steps. The state before the tick is recorded in the history.
+func (g *Game) Step() {
+	g.timeline.history.Push(g.tick, g.cells)
+	substeps := max(1, g.substeps)
+	dt := deltaT / float64(substeps)
 
-			// middle right
-			if currentXIndex < (screenWidth/attractionDistance - 1) {
-				partialForce := g.CalculatePartialForce(g.cells[i], g.partitionBoard[currentYIndex][currentXIndex+1])
+	hits := 0
+	for s := 0; s < substeps; s++ {
+		g.Integrate(dt)
 
-				totalForce.x += partialForce.x
-				totalForce.y += partialForce.y
+		for i := 0; i < len(g.cells); i++ {
+			if g.ApplyBoundary(g.cells[i]) {
+				hits++
 			}
+		}
+	}
 
-			// bottom left
-			if currentXIndex >= 1 && currentYIndex < (screenHeight/attractionDistance-1) {
-				partialForce := g.CalculatePartialForce(g.cells[i], g.partitionBoard[currentYIndex+1][currentXIndex-1])
+	g.ApplyReactions()
+	g.tick++
 
-				totalForce.x += partialForce.x
-				totalForce.y += partialForce.y
-			}
+	g.charts.Record(g, hits)
+}
 
-			// bottom middle
-			if currentYIndex < (screenHeight/attractionDistance - 1) {
-				partialForce := g.CalculatePartialForce(g.cells[i], g.partitionBoard[currentYIndex+1][currentXIndex])
+// RebuildGrid sorts the particles into the spatial grid, recreating it when the largest radius has changed.
+func (g *Game) RebuildGrid() {
+	// the partitions have to be at least as wide as the largest radius
+	if radius := g.MaxRadius(); g.grid == nil || g.grid.radius != radius {
+		g.grid = NewSpatialGrid(g.worldWidth, g.worldHeight, radius)
+	}
 
-				totalForce.x += partialForce.x
-				totalForce.y += partialForce.y
-			}
+	g.grid.Rebuild(g.cells)
 
-			// bottom right
-			if currentYIndex < (screenHeight/attractionDistance-1) && currentXIndex < (screenWidth/attractionDistance-1) {
-				partialForce := g.CalculatePartialForce(g.cells[i], g.partitionBoard[currentYIndex+1][currentXIndex+1])
+	if g.checkGrid {
+		if err := g.grid.Check(g.cells); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
 
-				totalForce.x += partialForce.x
-				totalForce.y += partialForce.y
-			}
+func (g *Game) Draw(screen *ebiten.Image) {
+	screen.Fill(color.Black)
 
-			totalForce.x *= attractionDistance
-			totalForce.y *= attractionDistance
+	g.renderer.Draw(screen, g)
 
-			/*
-				if totalForce.x >= 1000000 || math.IsNaN(totalForce.x) {
-					totalForce.x = 100000
-				}
+	g.clusters.Draw(screen, g)
+	g.brush.Draw(screen, g)
+	g.editor.Draw(screen, g)
+	g.charts.Draw(screen, g)
 
-				if totalForce.y >= 1000000 || math.IsNaN(totalForce.y) {
-					totalForce.y = 100000
-				}
-			*/
+	ebitenutil.DebugPrint(screen, fmt.Sprintf("TPS: %0.2f\nFPS: %0.2f\nSpecies: %d\nBoundary: %s\nCounts: %v\n%s\n%s\n%s\n%s\n%s", ebiten.ActualTPS(), ebiten.ActualFPS(), len(g.rules), g.boundary, g.speciesCounts, g.timeline.Status(g), g.brush.Status(), g.clusters.Status(), g.renderer.Status(), g.charts.Status()))
+}
 
-			g.cells[i].velocity.x *= friction
-			g.cells[i].velocity.y *= friction
+func (g *Game) Layout(outsideWidth, outsideHeight int) (int, int) {
+	return int(g.worldWidth), int(g.worldHeight)
+}
 
-			g.cells[i].velocity.x += totalForce.x * deltaT
-			g.cells[i].velocity.y += totalForce.y * deltaT
+func (g *Game) AddRule(a CellType, b CellType, force float64) {
+	g.rules[a][b] = force
+}
 
-			//fmt.Println()
-			//fmt.Println(totalForce.x)
-			//fmt.Println(g.cells[i].velocity.x)
+// MaxRadius returns the largest interaction radius of any species pair.
+func (g *Game) MaxRadius() float64 {
+	radius := 0.0
+	for _, row := range g.radii {
+		for _, r := range row {
+			radius = math.Max(radius, r)
 		}
+	}
+	return radius
+}
 
-		for i := 0; i < len(g.cells); i++ {
-
-			oldXIndex := ConvertToPartitionBoardIndex(g.cells[i].position.x)
-			oldYIndex := ConvertToPartitionBoardIndex(g.cells[i].position.y)
-
-			//println("")
-			//println(c.position.x)
-
-			g.cells[i].position.x += g.cells[i].velocity.x * deltaT
-			g.cells[i].position.y += g.cells[i].velocity.y * deltaT
-
-			//println(c.position.x)
-
-			/*
-				if g.cells[i].position.x < 0 {
-					g.cells[i].position.x += float64(screenWidth)
-				}
-
-				if g.cells[i].position.y < 0 {
-					g.cells[i].position.y += float64(screenHeight)
-				}
+func CellConstructor(cellType CellType, cellColor color.Color, position Vector2, size uint8) Cell {
+	return Cell{1.0, 1.0, cellType, cellColor, Vector2{0, 0}, position, size, 0}
+}
 
-				if g.cells[i].position.x > screenWidth {
-					g.cells[i].position.x = float64(uint(g.cells[i].position.x) % screenWidth)
-				}
+// AddCell gives c the next particle ID and adds it.
+func (g *Game) AddCell(c Cell) *Cell {
+	g.nextID++
+	c.id = g.nextID
+	g.cells = append(g.cells, &c)
+	g.speciesCounts[c.cellType]++
+	return &c
+}
 
-				if g.cells[i].position.y > screenHeight {
-					g.cells[i].position.y = float64(uint(g.cells[i].position.y) % screenHeight)
-				}
-			*/
+// SpawnCells adds n particles at random positions, with species following g.mix and masses and charges drawn from
+// the species' distributions.
+func (g *Game) SpawnCells(n int, rng *rand.Rand) {
+	for i := 0; i < n; i++ {
+		cellType := SpawnType(g.mix, i, n)
 
-			if g.cells[i].position.x < 0 {
-				g.cells[i].position.x = screenWidth / 2
-			}
+		randX := rng.Intn(int(g.worldWidth) - cellSize)
+		randY := rng.Intn(int(g.worldHeight) - cellSize)
 
-			if g.cells[i].position.y < 0 {
-				g.cells[i].position.y = screenHeight / 2
-			}
+		c := g.AddCell(CellConstructor(cellType, g.colors[cellType], Vector2{float64(randX), float64(randY)}, 2))
+		c.mass = SpeciesDistribution(g.masses, cellType).Sample(rng)
+		c.charge = SpeciesDistribution(g.charges, cellType).Sample(rng)
+	}
+}
 
-			if g.cells[i].position.x > screenWidth {
-				g.cells[i].position.x = screenWidth / 2
-			}
+func main() {
+	species := flag.Int("species", 4, "number of particle species")
+	colors := flag.String("colors", "", "comma separated hex colors per species, e.g. #ff0000,#00ff00; generated when empty")
+	mix := flag.String("mix", "", "comma separated spawn proportions per species, e.g. 1,1,2,1; uniform when empty")
+	cellCount := flag.Int("cells", 1000, "number of particles")
+	width := flag.Int("width", screenWidth, "world width")
+	height := flag.Int("height", screenHeight, "world height")
+	radius := flag.Float64("radius", defaultAttractionDistance, "default interaction radius of every species pair")
+	boundary := flag.String("boundary", "reset", "what happens at the world edges: reset, wrap or walls")
+	halfLife := flag.Float64("halflife", defaultHalfLife, "simulated time for a velocity to halve through friction, 0 for none")
+	substeps := flag.Int("substeps", 1, "integrator steps per tick")
+	masses := flag.String("mass", "", "comma separated mass per species, each mean or mean:spread, e.g. 1,4:1; 1 when empty")
+	charges := flag.String("charge", "", "comma separated charge per species, each mean or mean:spread; 1 when empty")
+	massRadius := flag.Bool("massradius", false, "draw particles with an area proportional to their mass")
+	law := flag.String("law", "linear", "force law of every species pair: "+strings.Join(ForceLawNames(), ", "))
+	integrator := flag.String("integrator", "euler", "integration scheme: euler (semi-implicit), verlet or rk4")
+	presetPath := flag.String("preset", "", "load rules, colors, radii, friction, integrator and seed from a preset file")
+	share := flag.String("share", "", "load a preset from a share string printed when saving")
+	presetDir := flag.String("presets", "presets", "directory that S saves presets to and P cycles through")
+	workers := flag.Int("workers", runtime.NumCPU(), "goroutines used to compute forces")
+	historySize := flag.Int("history", 600, "ticks kept for stepping backwards")
+	stepCount := flag.Int("stepn", 10, "ticks the right arrow steps forward and shift+left steps back")
+	until := flag.Int("until", -1, "play until this tick, then pause; -1 starts paused")
+	snapshotDir := flag.String("snapshots", "snapshots", "directory that X exports frame snapshots to")
+	seed := flag.Int64("seed", 0, "seed for particle positions and random rules, overriding a preset's; 0 picks one")
+	restore := flag.String("restore", "", "continue from a snapshot file exported with X")
+	clusterLink := flag.Float64("clusterlink", 40, "particles closer than this belong to the same cluster")
+	clusterMin := flag.Int("clustermin", 5, "smallest group of particles counted as a cluster")
+	clusterTicks := flag.Int("clusters", 0, "simulate this many ticks headless, tracking clusters, then print a cluster report and exit")
+	explore := flag.Int("explore", 0, "score this many random rule sets headless, writing presets, thumbnails and a ranking, then exit")
+	exploreTicks := flag.Int("exploreticks", 500, "ticks each explored rule set is simulated for")
+	exploreDir := flag.String("exploreout", "explore", "directory the explorer writes into")
+	reactionPath := flag.String("reactions", "", "JSON file with a list of reactions {from, with, to, radius, probability, energy}")
+	checkGrid := flag.Bool("checkgrid", false, "validate the spatial grid every tick and exit on the first inconsistency")
+	longRange := flag.String("longrange", "", "long range force between every pair on top of the pair laws: "+strings.Join(LongRangeLawNames(), ", ")+"; none when empty")
+	longRangeStrength := flag.Float64("longstrength", defaultLongRangeStrength, "long range force between unit charges at the default radius, scaled by the pair's rule")
+	softening := flag.Float64("softening", defaultSoftening, "distance over which the long range force is softened")
+	theta := flag.Float64("theta", defaultTheta, "Barnes-Hut opening angle, smaller is more accurate and slower")
+	direct := flag.Bool("direct", false, "sum the long range force over every pair instead of using the Barnes-Hut tree")
+	chartLength := flag.Int("charts", 300, "ticks shown by the charts that G toggles, no charts when 0")
+	csvPath := flag.String("csv", "", "stream kinetic energy, mean speed, boundary hits and species counts of every tick to this CSV file")
+	flag.Parse()
+
+	scheme, ok := integrators[*integrator]
+	if !ok {
+		log.Fatalf("unknown integrator %q", *integrator)
+	}
 
-			if g.cells[i].position.y > screenHeight {
-				g.cells[i].position.y = screenHeight / 2
-			}
+	longRangeLaw, err := ParseLongRangeLaw(*longRange)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-			newXIndex := ConvertToPartitionBoardIndex(g.cells[i].position.x)
-			newYIndex := ConvertToPartitionBoardIndex(g.cells[i].position.y)
+	if *theta < 0 || *softening < 0 {
+		log.Fatal("theta and softening must not be negative")
+	}
 
-			//fmt.Printf("%d %d %d %d\n", g.cells[i].position.x, g.cells[i].position.y, newXIndex, newYIndex)
+	defaultLaw, err := ParseForceLaw(*law)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-			j := 0
+	boundaryMode, ok := boundaryModes[*boundary]
+	if !ok {
+		log.Fatalf("unknown boundary mode %q", *boundary)
+	}
 
-			//println("NEWNEWNEWNEWNEWNEWNENWENWNEWNENWENWNEWNENWENWENWN")
-			//println(g.cells[i])
-			for o := 0; o < len(g.partitionBoard[oldYIndex][oldXIndex]); o++ {
-				//fmt.Printf("%p\n", g.partitionBoard[oldYIndex][oldXIndex][o])
-			}
+	if *width <= cellSize || *height <= cellSize {
+		log.Fatal("world size must be positive")
+	}
 
-			for j < len(g.partitionBoard[oldYIndex][oldXIndex]) {
-				if g.partitionBoard[oldYIndex][oldXIndex][j] == g.cells[i] {
-					//fmt.Printf("DELETING:\n")
-					//fmt.Printf("%p\n", g.partitionBoard[oldYIndex][oldXIndex][j])
-					//fmt.Printf("%p\n\n", g.cells[i])
-					g.partitionBoard[oldYIndex][oldXIndex] = slices.Delete(g.partitionBoard[oldYIndex][oldXIndex], j, j+1)
-					//g.partitionBoard[oldYIndex][oldXIndex][j] = g.partitionBoard[oldYIndex][oldXIndex][len(g.partitionBoard[oldYIndex][oldXIndex])-1]
-					//g.partitionBoard[oldYIndex][oldXIndex] = g.partitionBoard[oldYIndex][oldXIndex][:len(g.partitionBoard[oldYIndex][oldXIndex])-1]
-					//fmt.Println("DELETING")
-					break
-				}
-				j++
-			}
+	// the editor's range, so that editing a radius never snaps it
+	if radii := editorMatrices[EditRadii]; !(*radius >= radii.min && *radius <= radii.max) {
+		log.Fatalf("radius must be between %g and %g", radii.min, radii.max)
+	}
 
-			//println("------------------------------------------------------------")
+	ebiten.SetWindowSize(*width, *height)
+	ebiten.SetWindowTitle("Hello, World!")
 
-			for o := 0; o < len(g.partitionBoard[oldYIndex][oldXIndex]); o++ {
-				//fmt.Printf("%p\n", g.partitionBoard[oldYIndex][oldXIndex][o])
-			}
+	g := &Game{
+		requiresInput:      true,
+		worldWidth:         float64(*width),
+		worldHeight:        float64(*height),
+		attractionDistance: *radius,
+		halfLife:           *halfLife,
+		substeps:           *substeps,
+		integrator:         scheme,
+		defaultLaw:         defaultLaw,
+		massRadius:         *massRadius,
+		longRange:          longRangeLaw,
+		longRangeStrength:  *longRangeStrength,
+		softening:          *softening,
+		theta:              *theta,
+		longRangeDirect:    *direct,
+		clusters: ClusterTracker{
+			linkDistance: *clusterLink,
+			minSize:      max(1, *clusterMin),
+		},
+		seed:        rand.Int63(),
+		presetDir:   *presetDir,
+		presetIndex: -1,
+		boundary:    boundaryMode,
+		checkGrid:   *checkGrid,
+		workers:     *workers,
+		charts:      NewCharts(max(0, *chartLength)),
+		timeline: Timeline{
+			history:     NewHistory(*historySize),
+			stepCount:   max(1, *stepCount),
+			target:      *until,
+			snapshotDir: *snapshotDir,
+		},
+	}
 
-			//println("NEWNEWNEWNEWNEWNEWNENWENWNEWNENWENWNEWNENWENWENWN")
+	if *colors != "" {
+		var err error
+		if g.configColors, err = ParseColors(*colors); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-			for o := 0; o < len(g.partitionBoard[newYIndex][newXIndex]); o++ {
-				//fmt.Printf("%p\n", g.partitionBoard[newYIndex][newXIndex][o])
-			}
-			//println("------------------------------------------------------------")
-			g.partitionBoard[newYIndex][newXIndex] = append(g.partitionBoard[newYIndex][newXIndex], g.cells[i])
+	if *mix != "" {
+		var err error
+		if g.mix, err = ParseMix(*mix); err != nil {
+			log.Fatal(err)
+		}
+		*species = len(g.mix)
+	}
 
-			for o := 0; o < len(g.partitionBoard[newYIndex][newXIndex]); o++ {
-				//fmt.Printf("%p\n", g.partitionBoard[newYIndex][newXIndex][o])
+	if *masses != "" {
+		var err error
+		if g.masses, err = ParseDistributions(*masses); err != nil {
+			log.Fatal(err)
+		}
+		for _, d := range g.masses {
+			if d.mean-d.spread <= 0 {
+				log.Fatal("masses must be positive")
 			}
-
 		}
 	}
 
-	if inpututil.IsKeyJustPressed(ebiten.KeyA) {
-		g.requiresInput = !g.requiresInput
+	if *charges != "" {
+		var err error
+		if g.charges, err = ParseDistributions(*charges); err != nil {
+			log.Fatal(err)
+		}
 	}
 
-	if inpututil.IsKeyJustPressed(ebiten.KeyR) {
-		g.RandomizeRules()
+	g.SetSpeciesCount(*species)
+	if len(g.rules) != *species {
+		log.Fatalf("species count must be between 1 and %d", maxSpecies)
 	}
 
-	return nil
-}
-
-func (g *Game) Draw(screen *ebiten.Image) {
-	screen.Fill(color.Black)
-
-	for i := 0; i < len(g.cells); i++ {
-		g.cells[i].Draw(screen)
+	var preset *Preset
+	if *presetPath != "" {
+		p, err := LoadPreset(*presetPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		preset = &p
 	}
 
-	ebitenutil.DebugPrint(screen, fmt.Sprintf("TPS: %0.2f\nFPS: %0.2f", ebiten.ActualTPS(), ebiten.ActualFPS()))
-}
-
-func (g *Game) Layout(outsideWidth, outsideHeight int) (int, int) {
-	return screenWidth, screenHeight
-}
-
-func (g *Game) AddRule(a CellType, b CellType, force float64) {
-	g.rules[a][b] = force
-}
-
-func CellConstructor(cellType CellType, position Vector2, size uint8) Cell {
-	var cellColor color.Color
-	switch cellType {
-	case RedCell:
-		cellColor = color.RGBA{255, 0, 0, 255}
-	case BlueCell:
-		cellColor = color.RGBA{0, 0, 255, 255}
-	case GreenCell:
-		cellColor = color.RGBA{0, 255, 0, 255}
-	case WhiteCell:
-		cellColor = color.RGBA{255, 255, 255, 255}
-	}
-	return Cell{1.0, cellType, cellColor, Vector2{0, 0}, position, size}
-}
-
-func main() {
-	ebiten.SetWindowSize(screenWidth, screenHeight)
-	ebiten.SetWindowTitle("Hello, World!")
-
-	g := &Game{requiresInput: true}
-
-	i := 0
-	for y := 0; y < 1000; y++ {
-		var cellType CellType
-		switch i % 4 {
-		case 0:
-			cellType = RedCell
-		case 1:
-			cellType = BlueCell
-		case 2:
-			cellType = GreenCell
-		case 3:
-			cellType = WhiteCell
+	if *share != "" {
+		p, err := DecodePreset(*share)
+		if err != nil {
+			log.Fatal(err)
 		}
+		preset = &p
+	}
 
-		randX := rand.Intn(screenWidth - cellSize)
-		randY := rand.Intn(screenHeight - cellSize)
-
-		c := CellConstructor(cellType, Vector2{float64(randX), float64(randY)}, 2)
-		//g.cells[i] = c
-		g.cells = append(g.cells, &c)
+	if preset != nil {
+		if err := g.ApplyPreset(*preset); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-		xIndex := ConvertToPartitionBoardIndex(c.position.x)
-		yIndex := ConvertToPartitionBoardIndex(c.position.y)
+	if *reactionPath != "" {
+		reactions, err := LoadReactions(*reactionPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		g.reactions = reactions
+		if err := g.CheckReactions(); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-		g.partitionBoard[yIndex][xIndex] = append(g.partitionBoard[yIndex][xIndex], &c)
-		i++
+	if *seed != 0 {
+		g.seed = *seed
 	}
+	g.Seed(g.seed)
+	fmt.Printf("seed %d\n", g.seed)
 
-	//fmt.Printf("%p\n", &g.partitionBoard[0][0][0])
-	//fmt.Printf("%p\n", &g.cells[0])
+	g.SpawnCells(*cellCount, g.rng)
 
 	/*
 		g.AddRule(RedCell, RedCell, -1)
@@ -435,7 +532,40 @@ func main() {
 		g.AddRule(WhiteCell, WhiteCell, 1)
 	*/
 
-	g.RandomizeRules()
+	if preset == nil {
+		g.RandomizeRules()
+	} else {
+		g.PrintRules()
+	}
+
+	if *restore != "" {
+		if err := g.RestoreSnapshotFile(*restore); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *explore > 0 {
+		if err := g.RunExplorer(*explore, *cellCount, *exploreTicks, *workers, *exploreDir); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *csvPath != "" {
+		if err := g.charts.Stream(*csvPath); err != nil {
+			log.Fatal(err)
+		}
+		defer g.charts.Close()
+	}
+
+	if *clusterTicks > 0 {
+		for t := 0; t < *clusterTicks; t++ {
+			g.Step()
+			g.clusters.Refresh(g)
+		}
+		fmt.Print(g.clusters.Report(g))
+		return
+	}
 
 	println(len(g.cells))
 
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Number of partitions a force worker claims at a time
+const partitionsPerJob = 4
+
+// TotalForce sums the force on particle i from every particle in the 3x3 block of partitions around it, and the long
+// range force from all of them.
+func (g *Game) TotalForce(i int) Vector2 {
+	totalForce := Vector2{0, 0}
+
+	column, row := g.grid.Index(g.cells[i].position)
+
+	rows, rowCount := g.NeighborIndices(row, g.grid.rows)
+	columns, columnCount := g.NeighborIndices(column, g.grid.columns)
+	for _, neighborRow := range rows[:rowCount] {
+		for _, neighborColumn := range columns[:columnCount] {
+			partialForce := g.CalculatePartialForce(i, g.grid.Partition(neighborColumn, neighborRow))
+
+			totalForce.x += partialForce.x
+			totalForce.y += partialForce.y
+		}
+	}
+
+	if g.longRange != nil {
+		longRange := g.LongRangeForce(i)
+		totalForce.x += longRange.x
+		totalForce.y += longRange.y
+	}
+
+	return totalForce
+}
+
+// ComputeAccelerations rebuilds the grid and writes the acceleration of every particle into out. Workers claim
+// partitions in chunks, and since a particle's acceleration only depends on positions, which do not change during
+// this phase, and its own sum always runs in grid order, the result does not depend on the number of workers. The
+// Barnes–Hut tree is built once up front and only read by the workers.
+func (g *Game) ComputeAccelerations(out []Vector2) {
+	g.RebuildGrid()
+	if g.longRange != nil && !g.longRangeDirect {
+		g.tree.Build(g)
+	}
+
+	partitions := g.grid.columns * g.grid.rows
+	workers := max(1, g.workers)
+
+	var next atomic.Int64
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				start := int(next.Add(partitionsPerJob)) - partitionsPerJob
+				if start >= partitions {
+					return
+				}
+
+				for p := start; p < min(start+partitionsPerJob, partitions); p++ {
+					for _, i := range g.grid.Partition(p%g.grid.columns, p/g.grid.columns) {
+						force := g.TotalForce(i)
+						out[i] = Vector2{force.x / g.cells[i].mass, force.y / g.cells[i].mass}
+					}
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+// newStepGame returns n particles of four random species. The world grows with the count so that the density, and
+// with it the work per particle, stays the same as 1000 particles in the default world.
+func newStepGame(n, workers int) *Game {
+	scale := math.Sqrt(float64(n) / 1000)
+
+	g := &Game{
+		worldWidth:         screenWidth * scale,
+		worldHeight:        screenHeight * scale,
+		attractionDistance: defaultAttractionDistance,
+		halfLife:           defaultHalfLife,
+		workers:            workers,
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	g.SetSpeciesCount(4)
+	for i := range g.rules {
+		for j := range g.rules[i] {
+			g.rules[i][j] = rng.Float64()*2 - 1
+		}
+	}
+	g.SpawnCells(n, rng)
+	return g
+}
+
+func BenchmarkStep(b *testing.B) {
+	for _, n := range []int{1000, 5000, 10000, 20000, 50000} {
+		b.Run(fmt.Sprintf("particles=%d", n), func(b *testing.B) {
+			g := newStepGame(n, runtime.NumCPU())
+
+			// let the first clumps form before timing
+			for t := 0; t < 10; t++ {
+				g.Step()
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				g.Step()
+			}
+			b.ReportMetric(float64(n*b.N)/b.Elapsed().Seconds(), "particles/s")
+		})
+	}
+}
+
+func TestWorkersDeterministic(t *testing.T) {
+	workers := max(4, runtime.NumCPU())
+
+	tests := []struct {
+		name       string
+		integrator Integrator
+		boundary   BoundaryMode
+	}{
+		{"euler reset", SemiImplicitEuler, BoundaryReset},
+		{"verlet walls", VelocityVerlet, BoundaryWalls},
+		{"rk4 wrap", RungeKutta4, BoundaryWrap},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			games := []*Game{newStepGame(1000, 1), newStepGame(1000, workers)}
+			for _, g := range games {
+				g.integrator = test.integrator
+				g.boundary = test.boundary
+				g.substeps = 2
+			}
+
+			// the force phase on its own, then whole ticks
+			forces := [][]Vector2{make([]Vector2, 1000), make([]Vector2, 1000)}
+			for k, g := range games {
+				g.ComputeAccelerations(forces[k])
+			}
+			for i := range forces[0] {
+				if forces[0][i] != forces[1][i] {
+					t.Fatalf("particle %d accelerates by %v with 1 worker and %v with %d", i, forces[0][i], forces[1][i], workers)
+				}
+			}
+
+			for tick := 0; tick < 20; tick++ {
+				for _, g := range games {
+					g.Step()
+				}
+			}
+			for i := range games[0].cells {
+				a, b := games[0].cells[i], games[1].cells[i]
+				if a.position != b.position || a.velocity != b.velocity {
+					t.Fatalf("particle %d is at %v with 1 worker and %v with %d", i, a.position, b.position, workers)
+				}
+			}
+		})
+	}
+}
+
+func TestTotalForceDoesNotAllocate(t *testing.T) {
+	g := newStepGame(1000, 1)
+	g.RebuildGrid()
+
+	allocations := testing.AllocsPerRun(10, func() {
+		for i := range g.cells {
+			g.TotalForce(i)
+		}
+	})
+	if allocations != 0 {
+		t.Errorf("summing the forces allocates %g times", allocations)
+	}
+}
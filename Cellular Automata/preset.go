@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// 2 added per species pair radii and betas, 3 replaced friction with a half-life and added the integrator, 4 added
+	// per species pair force laws, 5 added reactions, 6 added the long range force
+	presetVersion = 6
+
+	// Share strings are "pl<version>." followed by the deflated preset JSON in URL-safe base64
+	sharePrefix = "pl1."
+)
+
+// Preset is a rule set together with everything needed to reproduce it, stored as versioned JSON.
+type Preset struct {
+	Version    int         `json:"version"`
+	Species    int         `json:"species"`
+	Colors     []string    `json:"colors"`
+	Rules      [][]float64 `json:"rules"`
+	Radius     float64     `json:"radius"`
+	Radii      [][]float64 `json:"radii,omitempty"`
+	Betas      [][]float64 `json:"betas,omitempty"`
+	Laws       [][]string  `json:"laws,omitempty"`
+	Reactions  []Reaction  `json:"reactions,omitempty"`
+	HalfLife   float64     `json:"halfLife"`
+	Friction   float64     `json:"friction,omitempty"` // per tick velocity factor of version 1 and 2 presets
+	Integrator string      `json:"integrator,omitempty"`
+	Substeps   int         `json:"substeps,omitempty"`
+	Seed       int64       `json:"seed"`
+
+	LongRange         string  `json:"longRange,omitempty"`
+	LongRangeStrength float64 `json:"longRangeStrength,omitempty"`
+	Softening         float64 `json:"softening,omitempty"`
+	Theta             float64 `json:"theta,omitempty"`
+}
+
+func (g *Game) Preset() Preset {
+	p := Preset{
+		Version:    presetVersion,
+		Species:    len(g.rules),
+		Radius:     g.attractionDistance,
+		HalfLife:   g.halfLife,
+		Integrator: g.integrator.String(),
+		Substeps:   g.substeps,
+		Seed:       g.seed,
+		Reactions:  append([]Reaction{}, g.reactions...),
+
+		LongRangeStrength: g.longRangeStrength,
+		Softening:         g.softening,
+		Theta:             g.theta,
+	}
+
+	if g.longRange != nil {
+		p.LongRange = g.longRange.Name()
+	}
+
+	for _, c := range g.colors {
+		rgba := color.RGBAModel.Convert(c).(color.RGBA)
+		p.Colors = append(p.Colors, fmt.Sprintf("#%02x%02x%02x", rgba.R, rgba.G, rgba.B))
+	}
+
+	for i := range g.rules {
+		p.Rules = append(p.Rules, append([]float64{}, g.rules[i]...))
+		p.Radii = append(p.Radii, append([]float64{}, g.radii[i]...))
+		p.Betas = append(p.Betas, append([]float64{}, g.betas[i]...))
+
+		laws := []string{}
+		for _, law := range g.laws[i] {
+			laws = append(laws, law.Name())
+		}
+		p.Laws = append(p.Laws, laws)
+	}
+
+	return p
+}
+
+// ApplyPreset replaces the rules, radii, betas, force laws, long range force, reactions, colors, friction half-life and integrator. The seed only takes effect
+// when particles are spawned.
+func (g *Game) ApplyPreset(p Preset) error {
+	if p.Version < 1 || p.Version > presetVersion {
+		return fmt.Errorf("unsupported preset version %d", p.Version)
+	}
+
+	if p.Species < 1 || p.Species > maxSpecies || len(p.Rules) != p.Species {
+		return fmt.Errorf("preset has %d species and %d rule rows", p.Species, len(p.Rules))
+	}
+
+	// version 1 presets have a single radius and the fixed beta
+	if p.Radii == nil {
+		p.Radii = ResizeMatrix(nil, p.Species, func() float64 { return p.Radius })
+	}
+	if p.Betas == nil {
+		p.Betas = ResizeMatrix(nil, p.Species, func() float64 { return defaultBeta })
+	}
+
+	// presets before version 4 only know the linear law
+	if p.Laws == nil {
+		p.Laws = ResizeMatrix(nil, p.Species, PiecewiseLinear{}.Name)
+	}
+
+	if len(p.Laws) != p.Species {
+		return fmt.Errorf("preset has %d law rows, expected %d", len(p.Laws), p.Species)
+	}
+
+	laws := make([][]ForceLaw, p.Species)
+	for i, row := range p.Laws {
+		if len(row) != p.Species {
+			return fmt.Errorf("preset law row has %d entries, expected %d", len(row), p.Species)
+		}
+		for _, name := range row {
+			law, err := ParseForceLaw(name)
+			if err != nil {
+				return err
+			}
+			laws[i] = append(laws[i], law)
+		}
+	}
+
+	for _, m := range [][][]float64{p.Rules, p.Radii, p.Betas} {
+		if len(m) != p.Species {
+			return fmt.Errorf("preset matrix has %d rows, expected %d", len(m), p.Species)
+		}
+		for _, row := range m {
+			if len(row) != p.Species {
+				return fmt.Errorf("preset matrix row has %d entries, expected %d", len(row), p.Species)
+			}
+		}
+	}
+
+	// older presets multiplied velocities by friction once per tick
+	if p.Version < 3 {
+		p.HalfLife = 0
+		if p.Friction > 0 && p.Friction < 1 {
+			p.HalfLife = deltaT * math.Log(0.5) / math.Log(p.Friction)
+		}
+	}
+
+	scheme := g.integrator
+	if p.Integrator != "" {
+		var ok bool
+		if scheme, ok = integrators[p.Integrator]; !ok {
+			return fmt.Errorf("unknown preset integrator %q", p.Integrator)
+		}
+	}
+
+	if p.HalfLife < 0 || p.Substeps < 0 {
+		return fmt.Errorf("preset half-life and substeps must not be negative")
+	}
+
+	longRange, err := ParseLongRangeLaw(p.LongRange)
+	if err != nil {
+		return err
+	}
+
+	if p.Softening < 0 || p.Theta < 0 {
+		return fmt.Errorf("preset softening and theta must not be negative")
+	}
+
+	// the editor's range, so that editing a radius never snaps it, and the same for the default radius of new species
+	radii := editorMatrices[EditRadii]
+	for _, row := range append([][]float64{{p.Radius}}, p.Radii...) {
+		for _, r := range row {
+			if !(r >= radii.min && r <= radii.max) {
+				return fmt.Errorf("preset radius %g must be between %g and %g", r, radii.min, radii.max)
+			}
+		}
+	}
+
+	// outside the editor's range the laws divide by zero or turn the core inside out
+	betas := editorMatrices[EditBetas]
+	for _, row := range p.Betas {
+		for _, beta := range row {
+			if !(beta >= betas.min && beta <= betas.max) {
+				return fmt.Errorf("preset beta %g must be between %g and %g", beta, betas.min, betas.max)
+			}
+		}
+	}
+
+	maxRadius := 0.0
+	for _, row := range p.Radii {
+		for _, r := range row {
+			maxRadius = math.Max(maxRadius, r)
+		}
+	}
+
+	for _, r := range p.Reactions {
+		if err := r.Validate(p.Species, maxRadius); err != nil {
+			return err
+		}
+	}
+
+	colors := []color.Color{}
+	if len(p.Colors) > 0 {
+		var err error
+		if colors, err = ParseColors(strings.Join(p.Colors, ",")); err != nil {
+			return err
+		}
+	}
+
+	// the same species count keeps every particle's species, which reactions and the brush may have changed, and the
+	// rewind history
+	g.configColors = colors
+	if p.Species != len(g.rules) {
+		g.SetSpeciesCount(p.Species)
+	} else {
+		g.RecolorCells()
+	}
+	for i := range p.Rules {
+		copy(g.rules[i], p.Rules[i])
+		copy(g.radii[i], p.Radii[i])
+		copy(g.betas[i], p.Betas[i])
+		copy(g.laws[i], laws[i])
+	}
+
+	g.reactions = append([]Reaction{}, p.Reactions...)
+	g.attractionDistance = p.Radius
+	g.halfLife = p.HalfLife
+	g.integrator = scheme
+	if p.Substeps > 0 {
+		g.substeps = p.Substeps
+	}
+	g.seed = p.Seed
+
+	// older presets keep the long range settings of the command line
+	if p.Version >= 6 {
+		g.longRange = longRange
+		g.longRangeStrength = p.LongRangeStrength
+		g.softening = p.Softening
+		g.theta = p.Theta
+	}
+
+	return nil
+}
+
+func LoadPreset(path string) (Preset, error) {
+	p := Preset{}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return p, err
+	}
+
+	err = json.Unmarshal(data, &p)
+	return p, err
+}
+
+func SavePreset(path string, p Preset) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
+
+// EncodePreset returns a compact string that can be pasted back with -share.
+func EncodePreset(p Preset) (string, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+
+	var compressed bytes.Buffer
+	w, err := flate.NewWriter(&compressed, flate.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	return sharePrefix + base64.RawURLEncoding.EncodeToString(compressed.Bytes()), nil
+}
+
+func DecodePreset(share string) (Preset, error) {
+	p := Preset{}
+
+	if !strings.HasPrefix(share, sharePrefix) {
+		return p, fmt.Errorf("share string must start with %q", sharePrefix)
+	}
+
+	compressed, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(share, sharePrefix))
+	if err != nil {
+		return p, err
+	}
+
+	data, err := io.ReadAll(flate.NewReader(bytes.NewReader(compressed)))
+	if err != nil {
+		return p, err
+	}
+
+	err = json.Unmarshal(data, &p)
+	return p, err
+}
+
+// SaveCurrentPreset writes the current rules into the presets directory and prints the share string.
+func (g *Game) SaveCurrentPreset() error {
+	if err := os.MkdirAll(g.presetDir, 0755); err != nil {
+		return err
+	}
+
+	// the name is reserved first so that a save never replaces another one, and the nanoseconds keep the names in the
+	// order LoadNextPreset cycles through
+	path := ""
+	for {
+		path = filepath.Join(g.presetDir, fmt.Sprintf("preset-%d.json", time.Now().UnixNano()))
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		f.Close()
+		break
+	}
+
+	p := g.Preset()
+	if err := SavePreset(path, p); err != nil {
+		return err
+	}
+
+	share, err := EncodePreset(p)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("saved %s\nshare: %s\n", path, share)
+	return nil
+}
+
+// LoadNextPreset applies the next preset in the presets directory, in file name order.
+func (g *Game) LoadNextPreset() error {
+	paths, err := filepath.Glob(filepath.Join(g.presetDir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	if len(paths) == 0 {
+		return fmt.Errorf("no presets in %s", g.presetDir)
+	}
+
+	sort.Strings(paths)
+	g.presetIndex = (g.presetIndex + 1) % len(paths)
+
+	p, err := LoadPreset(paths[g.presetIndex])
+	if err != nil {
+		return err
+	}
+
+	if err := g.ApplyPreset(p); err != nil {
+		return err
+	}
+
+	fmt.Printf("loaded %s\n", paths[g.presetIndex])
+	g.PrintRules()
+	return nil
+}
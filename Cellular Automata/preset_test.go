@@ -0,0 +1,230 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func newPresetGame(species, cells int) *Game {
+	g := &Game{
+		worldWidth:         1000,
+		worldHeight:        700,
+		attractionDistance: 100,
+		halfLife:           defaultHalfLife,
+		workers:            1,
+		timeline:           Timeline{history: NewHistory(10)},
+	}
+	g.Seed(1)
+	g.SetSpeciesCount(species)
+	g.SpawnCells(cells, g.Rand())
+	return g
+}
+
+func TestApplyPresetKeepsSpecies(t *testing.T) {
+	g := newPresetGame(3, 300)
+	for tick := 0; tick < 3; tick++ {
+		g.Step()
+	}
+
+	// what a reaction or the brush would leave behind
+	for i := 0; i < 50; i++ {
+		g.cells[i].cellType = BlueCell
+	}
+	g.CountSpecies()
+	types := []CellType{}
+	for _, c := range g.cells {
+		types = append(types, c.cellType)
+	}
+	counts := append([]int{}, g.speciesCounts...)
+
+	p := g.Preset()
+	p.Rules[0][1] = 0.25
+	p.Colors = []string{"#102030", "#405060", "#708090"}
+	if err := g.ApplyPreset(p); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, c := range g.cells {
+		if c.cellType != types[i] {
+			t.Fatalf("particle %d changed from species %d to %d", i, types[i], c.cellType)
+		}
+		if c.cellColor != g.colors[c.cellType] {
+			t.Fatalf("particle %d was not recolored", i)
+		}
+	}
+
+	if g.colors[2] != (color.RGBA{0x70, 0x80, 0x90, 0xff}) {
+		t.Errorf("species 3 has color %v, want #708090", g.colors[2])
+	}
+	for s := range counts {
+		if g.speciesCounts[s] != counts[s] {
+			t.Errorf("species counts %v, want %v", g.speciesCounts, counts)
+			break
+		}
+	}
+	if g.rules[0][1] != 0.25 {
+		t.Errorf("rule 1-2 is %g, want 0.25", g.rules[0][1])
+	}
+	if g.timeline.history.Len() != 3 {
+		t.Errorf("history holds %d frames, want 3", g.timeline.history.Len())
+	}
+}
+
+func TestApplyPresetResizesSpecies(t *testing.T) {
+	g := newPresetGame(3, 300)
+	g.Step()
+
+	p := newPresetGame(5, 0).Preset()
+	if err := g.ApplyPreset(p); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(g.rules) != 5 || len(g.colors) != 5 || len(g.speciesCounts) != 5 {
+		t.Fatalf("got %d rules, %d colors and %d counts, want 5", len(g.rules), len(g.colors), len(g.speciesCounts))
+	}
+
+	total := 0
+	for s, count := range g.speciesCounts {
+		if count == 0 {
+			t.Errorf("species %d has no particles after respawning the types", s)
+		}
+		total += count
+	}
+	if total != len(g.cells) {
+		t.Errorf("species counts add up to %d, want %d", total, len(g.cells))
+	}
+
+	if g.timeline.history.Len() != 0 {
+		t.Errorf("history holds %d frames of the old species", g.timeline.history.Len())
+	}
+}
+
+func TestSharePresetRoundTrip(t *testing.T) {
+	g := newPresetGame(4, 0)
+	g.RandomizeRules()
+
+	share, err := EncodePreset(g.Preset())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := DecodePreset(share)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := newPresetGame(4, 0)
+	h.SetSpeciesCount(2)
+	if err := h.ApplyPreset(p); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range g.rules {
+		for j := range g.rules[i] {
+			if h.rules[i][j] != g.rules[i][j] {
+				t.Fatalf("rule %d-%d is %g after the round trip, want %g", i, j, h.rules[i][j], g.rules[i][j])
+			}
+		}
+	}
+}
+
+func TestApplyPresetRejectsBetas(t *testing.T) {
+	for _, beta := range []float64{0, -0.1, 1, 1.5, math.NaN()} {
+		g := newPresetGame(2, 0)
+		p := g.Preset()
+		p.Betas[1][0] = beta
+		if err := g.ApplyPreset(p); err == nil {
+			t.Errorf("preset with beta %g was applied", beta)
+		}
+	}
+
+	// share strings go through the same check
+	g := newPresetGame(2, 0)
+	p := g.Preset()
+	p.Betas[0][1] = 0
+	share, err := EncodePreset(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodePreset(share)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.ApplyPreset(decoded); err == nil {
+		t.Error("share string with beta 0 was applied")
+	}
+}
+
+func TestReactionsLimitedByRadii(t *testing.T) {
+	g := newPresetGame(2, 0)
+	p := g.Preset()
+	p.Reactions = []Reaction{{From: 0, With: 1, To: 1, Radius: 80, Probability: 0.5}}
+	if err := g.ApplyPreset(p); err != nil {
+		t.Fatal(err)
+	}
+
+	p.Radii = [][]float64{{50, 50}, {50, 50}}
+	if err := g.ApplyPreset(p); err == nil {
+		t.Error("preset with a reaction reaching beyond every radius was applied")
+	}
+
+	// what the editor checks after lowering radii
+	for i := range g.radii {
+		for j := range g.radii[i] {
+			g.radii[i][j] = 60
+		}
+	}
+	if err := g.CheckReactions(); err == nil {
+		t.Error("reaction radius 80 passed with radii of 60")
+	}
+}
+
+func TestSaveCurrentPresetKeepsEverySave(t *testing.T) {
+	g := newPresetGame(2, 0)
+	g.presetDir = t.TempDir()
+
+	for i := 0; i < 5; i++ {
+		if err := g.SaveCurrentPreset(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	paths, err := filepath.Glob(filepath.Join(g.presetDir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 5 {
+		t.Fatalf("5 saves left %d presets", len(paths))
+	}
+	for _, path := range paths {
+		if _, err := LoadPreset(path); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+}
+
+func TestApplyPresetRejectsRadii(t *testing.T) {
+	for _, radius := range []float64{0, cellSize / 2, maxRadius + 1, math.NaN()} {
+		g := newPresetGame(2, 0)
+		p := g.Preset()
+		p.Radii[0][1] = radius
+		if err := g.ApplyPreset(p); err == nil {
+			t.Errorf("preset with radius %g was applied", radius)
+		}
+
+		p = g.Preset()
+		p.Radius = radius
+		if err := g.ApplyPreset(p); err == nil {
+			t.Errorf("preset with default radius %g was applied", radius)
+		}
+	}
+
+	g := newPresetGame(2, 0)
+	p := g.Preset()
+	p.Radii[1][0] = maxRadius
+	if err := g.ApplyPreset(p); err != nil {
+		t.Errorf("radius %d at the editor's maximum was rejected: %v", maxRadius, err)
+	}
+}
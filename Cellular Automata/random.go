@@ -0,0 +1,51 @@
+package main
+
+import "math/rand"
+
+// CountingSource is a seeded source that counts its draws, so that its state can be saved as the seed and count and
+// restored by drawing the same number of values again.
+type CountingSource struct {
+	source rand.Source64
+	seed   int64
+	draws  uint64
+}
+
+func NewCountingSource(seed int64, draws uint64) *CountingSource {
+	s := &CountingSource{source: rand.NewSource(seed).(rand.Source64), seed: seed}
+	for s.draws < draws {
+		s.Uint64()
+	}
+	return s
+}
+
+func (s *CountingSource) Int63() int64 {
+	s.draws++
+	return s.source.Int63()
+}
+
+func (s *CountingSource) Uint64() uint64 {
+	s.draws++
+	return s.source.Uint64()
+}
+
+func (s *CountingSource) Seed(seed int64) {
+	s.source.Seed(seed)
+	s.seed = seed
+	s.draws = 0
+}
+
+// Seed replaces the RNG with one seeded by seed. Everything random in a run, from spawn positions to new rules, is
+// drawn from it, so the seed reproduces the run.
+func (g *Game) Seed(seed int64) {
+	g.seed = seed
+	g.rngSource = NewCountingSource(seed, 0)
+	g.rng = rand.New(g.rngSource)
+}
+
+// Rand returns the game's RNG, seeding it with g.seed on first use.
+func (g *Game) Rand() *rand.Rand {
+	if g.rng == nil {
+		g.Seed(g.seed)
+	}
+	return g.rng
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+)
+
+// Reaction turns a particle of species From into To with probability Probability per tick while a particle of
+// species With is within Radius of it, so A+B→C+B. Energy is taken from the particle's kinetic energy, and the
+// reaction does not happen if the particle has too little; a negative cost speeds it up instead.
+type Reaction struct {
+	From        int     `json:"from"`
+	With        int     `json:"with"`
+	To          int     `json:"to"`
+	Radius      float64 `json:"radius"`
+	Probability float64 `json:"probability"`
+	Energy      float64 `json:"energy,omitempty"`
+}
+
+// Validate checks the reaction against a world with species species and a spatial grid of the given radius, which
+// limits how far reactions can reach.
+func (r Reaction) Validate(species int, gridRadius float64) error {
+	for _, t := range []int{r.From, r.With, r.To} {
+		if t < 0 || t >= species {
+			return fmt.Errorf("reaction %d+%d→%d refers to a species outside 0-%d", r.From, r.With, r.To, species-1)
+		}
+	}
+
+	if r.Radius <= 0 || r.Radius > gridRadius {
+		return fmt.Errorf("reaction radius %g must be positive and at most the largest interaction radius %g", r.Radius, gridRadius)
+	}
+
+	if r.Probability < 0 || r.Probability > 1 {
+		return fmt.Errorf("reaction probability %g must be between 0 and 1", r.Probability)
+	}
+
+	return nil
+}
+
+// CheckReactions validates every reaction against the current species and radii.
+func (g *Game) CheckReactions() error {
+	for _, r := range g.reactions {
+		if err := r.Validate(len(g.rules), g.MaxRadius()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func LoadReactions(path string) ([]Reaction, error) {
+	reactions := []Reaction{}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &reactions)
+	return reactions, err
+}
+
+// ApplyReactions runs every reaction once. Which particles react is decided on the state at the start, so a particle
+// that changes species this tick does not catalyze others until the next one. The RNG is only drawn from when a
+// particle is in contact, in particle order, so runs stay reproducible.
+func (g *Game) ApplyReactions() {
+	if len(g.reactions) == 0 {
+		return
+	}
+
+	g.RebuildGrid()
+	rng := g.Rand()
+
+	// in particle order, since applying a change draws from the RNG
+	type change struct{ i, to int }
+	changes := []change{}
+	for i, a := range g.cells {
+		for _, r := range g.reactions {
+			if int(a.cellType) != r.From || !g.InContact(i, CellType(r.With), r.Radius) {
+				continue
+			}
+
+			if rng.Float64() < r.Probability {
+				if g.PayReactionEnergy(a, r.Energy) {
+					changes = append(changes, change{i, r.To})
+				}
+				break
+			}
+		}
+	}
+
+	// like SetSpeciesCount, a particle takes a mass and charge of its new species, and keeps the kinetic energy the
+	// reaction left it
+	for _, change := range changes {
+		c := g.cells[change.i]
+		g.speciesCounts[c.cellType]--
+		c.cellType = CellType(change.to)
+		c.cellColor = g.colors[change.to]
+		g.speciesCounts[c.cellType]++
+
+		mass := SpeciesDistribution(g.masses, c.cellType).Sample(g.Rand())
+		scale := math.Sqrt(c.mass / mass)
+		c.velocity = Vector2{c.velocity.x * scale, c.velocity.y * scale}
+		c.mass = mass
+		c.charge = SpeciesDistribution(g.charges, c.cellType).Sample(g.Rand())
+	}
+}
+
+// InContact reports whether a particle of species with, other than i, is within radius of particle i.
+func (g *Game) InContact(i int, with CellType, radius float64) bool {
+	a := g.cells[i]
+	column, row := g.grid.Index(a.position)
+
+	rows, rowCount := g.NeighborIndices(row, g.grid.rows)
+	columns, columnCount := g.NeighborIndices(column, g.grid.columns)
+	for _, neighborRow := range rows[:rowCount] {
+		for _, neighborColumn := range columns[:columnCount] {
+			for _, j := range g.grid.Partition(neighborColumn, neighborRow) {
+				b := g.cells[j]
+				if b.cellType != with || b.id == a.id {
+					continue
+				}
+
+				d := g.Displacement(a.position, b.position)
+				if d.Magnitude() < radius {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// PayReactionEnergy takes cost from the particle's kinetic energy by scaling its speed, returning false without
+// changing anything when it has less than cost. Energy released by a negative cost pushes a resting particle in a
+// random direction.
+func (g *Game) PayReactionEnergy(c *Cell, cost float64) bool {
+	if cost == 0 {
+		return true
+	}
+
+	kinetic := 0.5 * c.mass * (c.velocity.x*c.velocity.x + c.velocity.y*c.velocity.y)
+	remaining := kinetic - cost
+	if remaining < 0 {
+		return false
+	}
+
+	if kinetic == 0 {
+		angle := g.Rand().Float64() * 2 * math.Pi
+		speed := math.Sqrt(2 * remaining / c.mass)
+		c.velocity = Vector2{math.Cos(angle) * speed, math.Sin(angle) * speed}
+		return true
+	}
+
+	scale := math.Sqrt(remaining / kinetic)
+	c.velocity = Vector2{c.velocity.x * scale, c.velocity.y * scale}
+	return true
+}
+
+// CountSpecies recounts the particles of every species. Adding, removing and reacting particles keep the counts up
+// to date, this is for changes to many particles at once.
+func (g *Game) CountSpecies() {
+	g.speciesCounts = make([]int, len(g.rules))
+	for _, c := range g.cells {
+		g.speciesCounts[c.cellType]++
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+// reactionGame returns a species 0 particle moving at velocity next to a resting species 1 particle, and the reaction
+// 0+1→1 with the given probability and energy.
+func reactionGame(probability, energy float64, velocity Vector2) *Game {
+	g := newPresetGame(2, 0)
+	g.masses = []Distribution{{2, 0}, {8, 0}}
+	g.charges = []Distribution{{1, 0}, {-0.5, 0}}
+
+	a := g.AddCell(CellConstructor(0, g.colors[0], Vector2{100, 100}, 2))
+	a.mass = 2
+	a.velocity = velocity
+	g.AddCell(CellConstructor(1, g.colors[1], Vector2{105, 100}, 2)).mass = 8
+
+	g.reactions = []Reaction{{From: 0, With: 1, To: 1, Radius: 20, Probability: probability, Energy: energy}}
+	return g
+}
+
+func TestReactionChangesSpecies(t *testing.T) {
+	g := reactionGame(1, 5, Vector2{3, 0})
+	g.ApplyReactions()
+
+	if !slices.Equal(g.speciesCounts, []int{0, 2}) {
+		t.Fatalf("species counts are %v after the reaction, want [0 2]", g.speciesCounts)
+	}
+
+	c := g.cells[0]
+	if c.cellType != 1 || c.cellColor != g.colors[1] {
+		t.Errorf("reacted particle is species %d in %v", c.cellType, c.cellColor)
+	}
+	if c.mass != 8 || c.charge != -0.5 {
+		t.Errorf("reacted particle has mass %g and charge %g, want those of species 1", c.mass, c.charge)
+	}
+
+	// 9 kinetic energy, less the cost of 5
+	if kinetic := 0.5 * c.mass * (c.velocity.x*c.velocity.x + c.velocity.y*c.velocity.y); math.Abs(kinetic-4) > 1e-12 {
+		t.Errorf("reacted particle has kinetic energy %g, want 4", kinetic)
+	}
+}
+
+func TestReactionNever(t *testing.T) {
+	g := reactionGame(0, 0, Vector2{3, 0})
+	for tick := 0; tick < 10; tick++ {
+		g.ApplyReactions()
+	}
+
+	if !slices.Equal(g.speciesCounts, []int{1, 1}) || g.cells[0].cellType != 0 || g.cells[0].mass != 2 {
+		t.Errorf("reaction of probability 0 changed the particles to counts %v", g.speciesCounts)
+	}
+}
+
+func TestPayReactionEnergy(t *testing.T) {
+	g := reactionGame(1, 10, Vector2{3, 0})
+	c := g.cells[0]
+
+	if g.PayReactionEnergy(c, 10) {
+		t.Error("a cost of 10 was paid from 9 kinetic energy")
+	}
+	if c.velocity != (Vector2{3, 0}) {
+		t.Errorf("refused payment changed the velocity to %v", c.velocity)
+	}
+
+	// nor does the reaction happen
+	g.ApplyReactions()
+	if !slices.Equal(g.speciesCounts, []int{1, 1}) {
+		t.Errorf("species counts are %v after a reaction the particle cannot pay for", g.speciesCounts)
+	}
+
+	if !g.PayReactionEnergy(c, 5) || math.Abs(c.velocity.x-2) > 1e-12 || c.velocity.y != 0 {
+		t.Errorf("paying 5 of 9 left velocity %v, want (2, 0)", c.velocity)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const (
+	spriteSize    = 16  // Side of the circle texture batched particles are drawn with
+	trailFade     = 20  // Alpha of the black drawn over the trail buffer every frame, out of 255
+	heatmapBin    = 8   // Side of a heatmap bin in pixels
+	velocityScale = 200 // Speed drawn at half brightness by the velocity mode
+
+	// particles per DrawTriangles call, limited by the uint16 indices; ebiten merges consecutive calls with the same
+	// source and options into one draw, so every particle still goes to the GPU at once
+	batchSize = min(ebiten.MaxVerticesCount/4, ebiten.MaxIndicesCount/6)
+)
+
+// trailDecay subtracts the source color from the destination's and keeps the destination's alpha.
+var trailDecay = ebiten.Blend{
+	BlendFactorSourceRGB:        ebiten.BlendFactorOne,
+	BlendFactorSourceAlpha:      ebiten.BlendFactorZero,
+	BlendFactorDestinationRGB:   ebiten.BlendFactorOne,
+	BlendFactorDestinationAlpha: ebiten.BlendFactorOne,
+	BlendOperationRGB:           ebiten.BlendOperationReverseSubtract,
+	BlendOperationAlpha:         ebiten.BlendOperationAdd,
+}
+
+type RenderMode uint8
+
+const (
+	RenderCircles  RenderMode = iota // An anti-aliased circle per particle
+	RenderBatched                    // Every particle in one triangle draw
+	RenderVelocity                   // Batched, with the hue from the direction and the brightness from the speed
+	RenderTrails                     // Batched into an accumulation buffer that fades every frame
+	RenderHeatmap                    // Particle density of every species, binned
+)
+
+var renderModes = []string{
+	RenderCircles:  "circles",
+	RenderBatched:  "batched",
+	RenderVelocity: "velocity",
+	RenderTrails:   "trails",
+	RenderHeatmap:  "heatmap",
+}
+
+func (m RenderMode) String() string {
+	return renderModes[m]
+}
+
+// Renderer draws the particles in the selected mode, V cycling through them. Its images and buffers are created on
+// first use and kept between frames.
+type Renderer struct {
+	mode RenderMode
+
+	sprite   *ebiten.Image
+	vertices []ebiten.Vertex
+	indices  []uint16
+
+	trails *ebiten.Image
+	decay  *ebiten.Image // one pixel of the smallest step, subtracted from the trails every frame
+
+	// heatmap is one pixel per bin, counts has an entry per bin and species
+	heatmap *ebiten.Image
+	pixels  []byte
+	counts  []int
+}
+
+func (r *Renderer) Update() {
+	if inpututil.IsKeyJustPressed(ebiten.KeyV) {
+		r.mode = (r.mode + 1) % RenderMode(len(renderModes))
+
+		// trails start over rather than showing where the particles were when the mode was last left
+		if r.mode == RenderTrails && r.trails != nil {
+			r.trails.Clear()
+		}
+	}
+}
+
+func (r *Renderer) Draw(screen *ebiten.Image, g *Game) {
+	switch r.mode {
+	case RenderCircles:
+		for _, c := range g.cells {
+			c.Draw(screen, g.massRadius)
+		}
+	case RenderBatched:
+		r.DrawBatched(screen, g, func(c *Cell) color.Color { return c.cellColor })
+	case RenderVelocity:
+		r.DrawBatched(screen, g, VelocityColor)
+	case RenderTrails:
+		r.DrawTrails(screen, g)
+	case RenderHeatmap:
+		r.DrawHeatmap(screen, g)
+	}
+}
+
+// DrawBatched draws every particle as a textured quad colored by colorOf.
+func (r *Renderer) DrawBatched(dst *ebiten.Image, g *Game, colorOf func(c *Cell) color.Color) {
+	if r.sprite == nil {
+		r.sprite = ebiten.NewImage(spriteSize, spriteSize)
+		vector.DrawFilledCircle(r.sprite, spriteSize/2, spriteSize/2, spriteSize/2, color.White, true)
+	}
+
+	// the indices of one full batch fit every batch, since each starts at vertex 0
+	if r.indices == nil {
+		for k := 0; k < batchSize; k++ {
+			v := uint16(4 * k)
+			r.indices = append(r.indices, v, v+1, v+2, v+1, v+3, v+2)
+		}
+	}
+
+	r.vertices = r.vertices[:0]
+	for _, c := range g.cells {
+		radius := float32(c.size)
+		if g.massRadius {
+			radius *= float32(math.Sqrt(c.mass))
+		}
+
+		// RGBA is premultiplied, which the draw options below are set to
+		red, green, blue, alpha := colorOf(c).RGBA()
+		cr, cg, cb, ca := float32(red)/0xffff, float32(green)/0xffff, float32(blue)/0xffff, float32(alpha)/0xffff
+
+		x, y := float32(c.position.x), float32(c.position.y)
+		for corner := 0; corner < 4; corner++ {
+			dx, dy := float32(corner&1), float32(corner>>1)
+			r.vertices = append(r.vertices, ebiten.Vertex{
+				DstX:   x + (2*dx-1)*radius,
+				DstY:   y + (2*dy-1)*radius,
+				SrcX:   dx * spriteSize,
+				SrcY:   dy * spriteSize,
+				ColorR: cr,
+				ColorG: cg,
+				ColorB: cb,
+				ColorA: ca,
+			})
+		}
+	}
+
+	options := &ebiten.DrawTrianglesOptions{ColorScaleMode: ebiten.ColorScaleModePremultipliedAlpha}
+	for start := 0; start < len(g.cells); start += batchSize {
+		count := min(batchSize, len(g.cells)-start)
+		dst.DrawTriangles(r.vertices[4*start:4*(start+count)], r.indices[:6*count], r.sprite, options)
+	}
+}
+
+// VelocityColor colors a particle by its direction of motion, brighter the faster it moves.
+func VelocityColor(c *Cell) color.Color {
+	hue := math.Mod(math.Atan2(c.velocity.y, c.velocity.x)*180/math.Pi+360, 360)
+	speed := c.velocity.Magnitude()
+	return HSVToRGB(hue, 0.8, 0.2+0.8*speed/(speed+velocityScale))
+}
+
+// DrawTrails fades the accumulation buffer, adds the particles to it and draws it.
+func (r *Renderer) DrawTrails(screen *ebiten.Image, g *Game) {
+	width, height := screen.Bounds().Dx(), screen.Bounds().Dy()
+	if r.trails == nil || r.trails.Bounds().Dx() != width || r.trails.Bounds().Dy() != height {
+		r.trails = ebiten.NewImage(width, height)
+	}
+
+	fade := color.RGBA{0, 0, 0, trailFade}
+	vector.DrawFilledRect(r.trails, 0, 0, float32(width), float32(height), fade, false)
+
+	// the fade rounds to the nearest 8-bit value, so it stalls once it takes off less than half a step, at 6 of 255
+	// for the default fade; subtracting one step more brings old trails all the way to black
+	if r.decay == nil {
+		r.decay = ebiten.NewImage(1, 1)
+		r.decay.Fill(color.RGBA{1, 1, 1, 255})
+	}
+	decay := &ebiten.DrawImageOptions{Blend: trailDecay}
+	decay.GeoM.Scale(float64(width), float64(height))
+	r.trails.DrawImage(r.decay, decay)
+
+	r.DrawBatched(r.trails, g, func(c *Cell) color.Color { return c.cellColor })
+	screen.DrawImage(r.trails, nil)
+}
+
+// DrawHeatmap bins the particles of every species and draws each bin in the species' colors mixed by how full it is,
+// on a log scale relative to the fullest bin of any species.
+func (r *Renderer) DrawHeatmap(screen *ebiten.Image, g *Game) {
+	columns := int(math.Ceil(g.worldWidth / heatmapBin))
+	rows := int(math.Ceil(g.worldHeight / heatmapBin))
+	species := len(g.rules)
+
+	if r.heatmap == nil || r.heatmap.Bounds().Dx() != columns || r.heatmap.Bounds().Dy() != rows {
+		r.heatmap = ebiten.NewImage(columns, rows)
+		r.pixels = make([]byte, 4*columns*rows)
+	}
+
+	if len(r.counts) != columns*rows*species {
+		r.counts = make([]int, columns*rows*species)
+	}
+	clear(r.counts)
+
+	largest := 0
+	for _, c := range g.cells {
+		column := min(columns-1, max(0, int(c.position.x/heatmapBin)))
+		row := min(rows-1, max(0, int(c.position.y/heatmapBin)))
+		k := (row*columns+column)*species + int(c.cellType)
+		r.counts[k]++
+		largest = max(largest, r.counts[k])
+	}
+
+	scale := 1 / math.Log1p(float64(max(1, largest)))
+	for bin := 0; bin < columns*rows; bin++ {
+		red, green, blue := 0.0, 0.0, 0.0
+		for s := 0; s < species; s++ {
+			count := r.counts[bin*species+s]
+			if count == 0 {
+				continue
+			}
+
+			intensity := math.Log1p(float64(count)) * scale
+			sr, sg, sb, _ := g.colors[s].RGBA()
+			red += intensity * float64(sr>>8)
+			green += intensity * float64(sg>>8)
+			blue += intensity * float64(sb>>8)
+		}
+
+		r.pixels[4*bin] = uint8(math.Min(255, red))
+		r.pixels[4*bin+1] = uint8(math.Min(255, green))
+		r.pixels[4*bin+2] = uint8(math.Min(255, blue))
+		r.pixels[4*bin+3] = 255
+	}
+	r.heatmap.WritePixels(r.pixels)
+
+	options := &ebiten.DrawImageOptions{Filter: ebiten.FilterLinear}
+	options.GeoM.Scale(heatmapBin, heatmapBin)
+	screen.DrawImage(r.heatmap, options)
+}
+
+// Status returns the render mode line of the debug text.
+func (r *Renderer) Status() string {
+	return fmt.Sprintf("Render: %s (V to cycle)", r.mode)
+}
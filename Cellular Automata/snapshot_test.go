@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// reactingGame returns a preset game whose reactions draw from the RNG every tick.
+func reactingGame(t *testing.T) *Game {
+	t.Helper()
+
+	g := newPresetGame(3, 400)
+	p := g.Preset()
+	p.Reactions = []Reaction{
+		{From: 0, With: 1, To: 1, Radius: 30, Probability: 0.05},
+		{From: 1, With: 2, To: 2, Radius: 30, Probability: 0.05},
+		{From: 2, With: 0, To: 0, Radius: 30, Probability: 0.05, Energy: 20},
+	}
+	if err := g.ApplyPreset(p); err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+// checkRestore simulates ticks, saves a snapshot, simulates ticks more, then restores the snapshot and checks that
+// simulating the same ticks again ends in exactly the same state, including the RNG.
+func checkRestore(t *testing.T, g *Game, ticks int) {
+	t.Helper()
+
+	for tick := 0; tick < ticks; tick++ {
+		g.Step()
+	}
+
+	saved, err := json.Marshal(g.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for tick := 0; tick < ticks; tick++ {
+		g.Step()
+	}
+
+	// draw once so a wrongly restored RNG shows up in the comparison
+	g.Rand().Float64()
+	expected, err := json.Marshal(g.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := Snapshot{}
+	if err := json.Unmarshal(saved, &s); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.RestoreSnapshot(s); err != nil {
+		t.Fatal(err)
+	}
+
+	for tick := 0; tick < ticks; tick++ {
+		g.Step()
+	}
+
+	g.Rand().Float64()
+	actual, err := json.Marshal(g.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("state after restoring tick %d and simulating %d ticks differs from the original run", s.Tick, ticks)
+	}
+}
+
+func TestRestoreWithReactions(t *testing.T) {
+	g := reactingGame(t)
+	draws := g.rngSource.draws
+
+	checkRestore(t, g, 50)
+
+	if g.rngSource.draws == draws {
+		t.Error("reactions drew nothing from the RNG, so the check proves nothing")
+	}
+}
+
+func TestRestoreAfterPresetSeed(t *testing.T) {
+	g := reactingGame(t)
+	for tick := 0; tick < 20; tick++ {
+		g.Step()
+	}
+
+	// loading a preset mid-run takes its seed, but the running RNG carries on
+	p := g.Preset()
+	p.Seed = 99
+	if err := g.ApplyPreset(p); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := g.Snapshot(); s.Preset.Seed != 1 {
+		t.Errorf("snapshot saves seed %d, want 1 of the running RNG", s.Preset.Seed)
+	}
+
+	checkRestore(t, g, 50)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// The first four entries of the default palette keep the colors of the original four species.
+var legacyColors = []color.Color{
+	color.RGBA{0, 255, 0, 255},
+	color.RGBA{255, 0, 0, 255},
+	color.RGBA{0, 0, 255, 255},
+	color.RGBA{255, 255, 255, 255},
+}
+
+// Palette returns n distinct colors, starting with the legacy ones and then stepping the hue by the golden angle.
+func Palette(n int) []color.Color {
+	colors := make([]color.Color, n)
+	for i := range colors {
+		if i < len(legacyColors) {
+			colors[i] = legacyColors[i]
+			continue
+		}
+
+		hue := math.Mod(float64(i-len(legacyColors))*137.508+30, 360)
+		colors[i] = HSVToRGB(hue, 0.8, 1)
+	}
+	return colors
+}
+
+// ParseColors reads a comma separated list of hex colors such as "#ff0000,#00ff00".
+func ParseColors(list string) ([]color.Color, error) {
+	colors := []color.Color{}
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimPrefix(strings.TrimSpace(entry), "#")
+
+		var r, g, b uint8
+		if _, err := fmt.Sscanf(entry, "%02x%02x%02x", &r, &g, &b); err != nil || len(entry) != 6 {
+			return nil, fmt.Errorf("invalid color %q", entry)
+		}
+		colors = append(colors, color.RGBA{r, g, b, 255})
+	}
+	return colors, nil
+}
+
+// ParseMix reads comma separated per-species spawn proportions such as "1,1,2,1".
+func ParseMix(list string) ([]float64, error) {
+	mix := []float64{}
+	for _, entry := range strings.Split(list, ",") {
+		proportion, err := strconv.ParseFloat(strings.TrimSpace(entry), 64)
+		if err != nil || proportion < 0 {
+			return nil, fmt.Errorf("invalid proportion %q", entry)
+		}
+		mix = append(mix, proportion)
+	}
+	return mix, nil
+}
+
+// Distribution is a uniform distribution over mean ± spread.
+type Distribution struct {
+	mean   float64
+	spread float64
+}
+
+// Sample draws from the distribution. A distribution without spread returns its mean without drawing, so it leaves the
+// RNG untouched.
+func (d Distribution) Sample(rng *rand.Rand) float64 {
+	if d.spread == 0 {
+		return d.mean
+	}
+	return d.mean + (rng.Float64()*2-1)*d.spread
+}
+
+// ParseDistributions parses comma separated distributions per species, each "mean" or "mean±spread" written as
+// "mean:spread", e.g. 1,4:1,1.
+func ParseDistributions(list string) ([]Distribution, error) {
+	distributions := []Distribution{}
+	for _, entry := range strings.Split(list, ",") {
+		mean, spread, _ := strings.Cut(strings.TrimSpace(entry), ":")
+
+		d := Distribution{}
+		var err error
+		if d.mean, err = strconv.ParseFloat(mean, 64); err != nil {
+			return nil, fmt.Errorf("invalid distribution %q", entry)
+		}
+		if spread != "" {
+			if d.spread, err = strconv.ParseFloat(spread, 64); err != nil || d.spread < 0 {
+				return nil, fmt.Errorf("invalid distribution %q", entry)
+			}
+		}
+		distributions = append(distributions, d)
+	}
+	return distributions, nil
+}
+
+// SpeciesDistribution returns the distribution of species t, or exactly 1 for species beyond the list.
+func SpeciesDistribution(distributions []Distribution, t CellType) Distribution {
+	if int(t) < len(distributions) {
+		return distributions[t]
+	}
+	return Distribution{1, 0}
+}
+
+// SpawnType picks the species of particle i out of total so that the species counts follow mix.
+func SpawnType(mix []float64, i int, total int) CellType {
+	sum := 0.0
+	for _, proportion := range mix {
+		sum += proportion
+	}
+
+	target := (float64(i) + 0.5) / float64(total) * sum
+	cumulative := 0.0
+	for species, proportion := range mix {
+		cumulative += proportion
+		if target < cumulative {
+			return CellType(species)
+		}
+	}
+	return CellType(len(mix) - 1)
+}
+
+// SetSpeciesCount resizes the rule, radius and beta matrices, keeping existing entries and randomizing new rules,
+// and respawns the particle types with a uniform mix.
+func (g *Game) SetSpeciesCount(n int) {
+	if n < 1 || n > maxSpecies {
+		return
+	}
+
+	g.rules = ResizeMatrix(g.rules, n, func() float64 { return g.Rand().Float64()*2 - 1 })
+	g.radii = ResizeMatrix(g.radii, n, func() float64 { return g.attractionDistance })
+	g.betas = ResizeMatrix(g.betas, n, func() float64 { return defaultBeta })
+	g.laws = ResizeMatrix(g.laws, n, g.DefaultLaw)
+
+	if len(g.mix) != n {
+		g.mix = make([]float64, n)
+		for i := range g.mix {
+			g.mix[i] = 1
+		}
+	}
+
+	// a particle that changes species takes a mass and charge of its new species
+	for i, c := range g.cells {
+		cellType := SpawnType(g.mix, i, len(g.cells))
+		if cellType != c.cellType {
+			c.cellType = cellType
+			c.mass = SpeciesDistribution(g.masses, cellType).Sample(g.Rand())
+			c.charge = SpeciesDistribution(g.charges, cellType).Sample(g.Rand())
+		}
+	}
+	g.RecolorCells()
+
+	// reactions between species that no longer exist are dropped
+	reactions := []Reaction{}
+	for _, r := range g.reactions {
+		if max(r.From, r.With, r.To) < n {
+			reactions = append(reactions, r)
+		}
+	}
+	g.reactions = reactions
+	g.CountSpecies()
+
+	// recorded frames hold the old species
+	g.timeline.history.Clear()
+}
+
+// RecolorCells picks the species colors again and repaints every particle in its species' color. Configured colors
+// take precedence, species beyond them fall back to the palette.
+func (g *Game) RecolorCells() {
+	g.colors = Palette(len(g.rules))
+	copy(g.colors, g.configColors)
+
+	for _, c := range g.cells {
+		c.cellColor = g.colors[c.cellType]
+	}
+}
+
+// DefaultLaw returns the force law of new species pairs.
+func (g *Game) DefaultLaw() ForceLaw {
+	if g.defaultLaw == nil {
+		return PiecewiseLinear{}
+	}
+	return g.defaultLaw
+}
+
+// ResizeMatrix returns an n by n copy of m, filling entries that m does not have with fill.
+func ResizeMatrix[T any](m [][]T, n int, fill func() T) [][]T {
+	resized := make([][]T, n)
+	for i := range resized {
+		resized[i] = make([]T, n)
+		for j := range resized[i] {
+			if i < len(m) && j < len(m[i]) {
+				resized[i][j] = m[i][j]
+			} else {
+				resized[i][j] = fill()
+			}
+		}
+	}
+	return resized
+}
+
+func HSVToRGB(hue, saturation, value float64) color.RGBA {
+	c := value * saturation
+	x := c * (1 - math.Abs(math.Mod(hue/60, 2)-1))
+	m := value - c
+
+	var r, g, b float64
+	switch {
+	case hue < 60:
+		r, g, b = c, x, 0
+	case hue < 120:
+		r, g, b = x, c, 0
+	case hue < 180:
+		r, g, b = 0, c, x
+	case hue < 240:
+		r, g, b = 0, x, c
+	case hue < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+
+	return color.RGBA{uint8((r + m) * 255), uint8((g + m) * 255), uint8((b + m) * 255), 255}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSetSpeciesCountResamples(t *testing.T) {
+	g := newPresetGame(2, 0)
+	g.masses = []Distribution{{1, 0}, {4, 0}, {9, 2}}
+	g.charges = []Distribution{{1, 0}, {-1, 0}, {0.5, 0.25}}
+	g.SpawnCells(300, g.Rand())
+
+	g.mix = nil
+	g.SetSpeciesCount(3)
+
+	for i, c := range g.cells {
+		mass, charge := g.masses[c.cellType], g.charges[c.cellType]
+		if math.Abs(c.mass-mass.mean) > mass.spread || math.Abs(c.charge-charge.mean) > charge.spread {
+			t.Fatalf("particle %d of species %d has mass %g and charge %g", i, c.cellType, c.mass, c.charge)
+		}
+	}
+	if g.speciesCounts[2] == 0 {
+		t.Error("no particle moved to the new species")
+	}
+}
+
+// twoCellForces returns the accelerations of two particles a fraction of the radius apart under an attracting rule.
+func twoCellForces(massA, chargeA, massB, chargeB float64) []Vector2 {
+	g := energyCheckGame(integrators["verlet"], 1, 0)
+	g.rules[0][0] = 1
+	a := addCheckCell(g, g.worldWidth/2, g.worldHeight/2, 0, 0)
+	b := addCheckCell(g, g.worldWidth/2+0.5*g.attractionDistance, g.worldHeight/2, 0, 0)
+	a.mass, a.charge = massA, chargeA
+	b.mass, b.charge = massB, chargeB
+
+	accelerations := make([]Vector2, 2)
+	g.ComputeAccelerations(accelerations)
+	return accelerations
+}
+
+func TestAccelerationScalesWithMass(t *testing.T) {
+	light := twoCellForces(1, 1, 1, 1)
+	heavy := twoCellForces(4, 1, 1, 1)
+
+	if light[0].x == 0 {
+		t.Fatal("no force between the particles")
+	}
+	if heavy[0].x*4 != light[0].x || heavy[0].y*4 != light[0].y {
+		t.Errorf("mass 4 accelerates by %v, want a quarter of %v", heavy[0], light[0])
+	}
+
+	// the heavy particle pulls just as hard on the other one
+	if heavy[1] != light[1] {
+		t.Errorf("the other particle accelerates by %v, want %v", heavy[1], light[1])
+	}
+}
+
+func TestForceScalesWithSourceCharge(t *testing.T) {
+	unit := twoCellForces(1, 1, 1, 1)
+	charged := twoCellForces(1, 1, 1, 2)
+
+	if charged[0].x != 2*unit[0].x || charged[0].y != 2*unit[0].y {
+		t.Errorf("source of charge 2 pulls by %v, want twice %v", charged[0], unit[0])
+	}
+
+	// a particle's own charge does not change the force on it
+	if charged[1] != unit[1] {
+		t.Errorf("the charge 2 particle accelerates by %v, want %v", charged[1], unit[1])
+	}
+
+	repelled := twoCellForces(1, 1, 1, -1)
+	if repelled[0].x != -unit[0].x {
+		t.Errorf("source of charge -1 pulls by %v, want the opposite of %v", repelled[0], unit[0])
+	}
+}
+
+func TestMomentumUnequalMasses(t *testing.T) {
+	for name, integrator := range integrators {
+		g := energyCheckGame(integrator, 1, 0)
+		g.rules[0][0] = 1
+		addCheckCell(g, g.worldWidth/2, g.worldHeight/2, 0, 0).mass = 1
+		addCheckCell(g, g.worldWidth/2+0.5*g.attractionDistance, g.worldHeight/2, 0, 0).mass = 7
+
+		for tick := 0; tick < 200; tick++ {
+			g.Step()
+			if momentum := g.Momentum(); momentum > 1e-9 {
+				t.Fatalf("%s: momentum reached %.3e at tick %d", name, momentum, g.tick)
+			}
+		}
+
+		if g.cells[0].velocity.Magnitude() == 0 {
+			t.Fatalf("%s: particles never moved", name)
+		}
+	}
+}